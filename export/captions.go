@@ -0,0 +1,189 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Captions defines a caption file for the speech in a single video, where
+// words are grouped into cues which end at a change of speaker, a pause, the
+// end of a sentence or when the cue reaches the maximum length or duration
+type Captions struct {
+	Format      CaptionFormat
+	MaxLine     int
+	MaxLines    int
+	MaxDuration time.Duration
+	cues        []*caption
+	videos      int
+}
+
+// CaptionFormat is the format of a caption file
+type CaptionFormat uint
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type caption struct {
+	start   time.Duration
+	end     time.Duration
+	speaker int64
+	words   []string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	CAPTION_SRT CaptionFormat = iota
+	CAPTION_VTT
+)
+
+const (
+	// Defaults for the length of each line and the number of lines per cue
+	caption_MAX_LINE     = 42
+	caption_MAX_LINES    = 2
+	caption_MAX_DURATION = 6 * time.Second
+	// A pause of at least this duration starts a new cue
+	caption_PAUSE = 1500 * time.Millisecond
+)
+
+var (
+	ErrTooManyVideos = errors.New("Captions are for a single video")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewCaptions returns an empty caption file
+func NewCaptions(format CaptionFormat) *Captions {
+	return &Captions{
+		Format:      format,
+		MaxLine:     caption_MAX_LINE,
+		MaxLines:    caption_MAX_LINES,
+		MaxDuration: caption_MAX_DURATION,
+		cues:        make([]*caption, 0),
+	}
+}
+
+// AddAnnotations adds the cues for the speech in a video. Returns
+// ErrTooManyVideos if called more than once
+func (this *Captions) AddAnnotations(uri string, annotations *service.Annotations) error {
+	if this.videos++; this.videos > 1 {
+		return ErrTooManyVideos
+	}
+	var cue *caption
+	for _, word := range annotations.Words() {
+		if cue != nil && this.fits(cue, word) == false {
+			cue = nil
+		}
+		if cue == nil {
+			cue = &caption{start: word.StartOffset, speaker: word.Speaker}
+			this.cues = append(this.cues, cue)
+		}
+		cue.words = append(cue.words, word.Word)
+		cue.end = word.EndOffset
+		if strings.HasSuffix(word.Word, ".") || strings.HasSuffix(word.Word, "?") || strings.HasSuffix(word.Word, "!") {
+			cue = nil
+		}
+	}
+	return nil
+}
+
+// Write outputs the caption file
+func (this *Captions) Write(w io.Writer) error {
+	var buf strings.Builder
+	if this.Format == CAPTION_VTT {
+		buf.WriteString("WEBVTT\n")
+	}
+	for i, cue := range this.cues {
+		lines := wrapWords(cue.words, this.MaxLine)
+		switch this.Format {
+		case CAPTION_SRT:
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(&buf, "%v\n%v --> %v\n", i+1, captionTime(cue.start, ","), captionTime(cue.end, ","))
+		case CAPTION_VTT:
+			fmt.Fprintf(&buf, "\n%v --> %v\n", captionTime(cue.start, "."), captionTime(cue.end, "."))
+			if cue.speaker > 0 {
+				lines[0] = fmt.Sprintf("<v Speaker %v>", cue.speaker) + lines[0]
+			}
+		}
+		fmt.Fprintln(&buf, strings.Join(lines, "\n"))
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// fits returns true if a word can be added to a cue
+func (this *Captions) fits(cue *caption, word *service.Word) bool {
+	if word.Speaker != cue.speaker {
+		return false
+	}
+	if word.StartOffset-cue.end >= caption_PAUSE {
+		return false
+	}
+	if this.MaxDuration > 0 && word.EndOffset-cue.start > this.MaxDuration {
+		return false
+	}
+	if this.MaxLine > 0 && this.MaxLines > 0 {
+		words := append(append(make([]string, 0, len(cue.words)+1), cue.words...), word.Word)
+		if len(wrapWords(words, this.MaxLine)) > this.MaxLines {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapWords returns lines of words no longer than max characters, unless
+// a single word is longer
+func wrapWords(words []string, max int) []string {
+	lines := make([]string, 0, 2)
+	line := ""
+	for _, word := range words {
+		if line == "" {
+			line = word
+		} else if max > 0 && len(line)+1+len(word) > max {
+			lines = append(lines, line)
+			line = word
+		} else {
+			line += " " + word
+		}
+	}
+	return append(lines, line)
+}
+
+// captionTime returns an offset as HH:MM:SS followed by a separator and
+// milliseconds
+func captionTime(offset time.Duration, separator string) string {
+	if offset < 0 {
+		offset = 0
+	}
+	ms := int64(offset / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d%v%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, separator, ms%1000)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (f CaptionFormat) String() string {
+	switch f {
+	case CAPTION_SRT:
+		return "CAPTION_SRT"
+	case CAPTION_VTT:
+		return "CAPTION_VTT"
+	default:
+		return "[?? Invalid CaptionFormat value]"
+	}
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+	"github.com/djthorpe/VideoIntelligence/timecode"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// EDL defines a CMX 3600 Edit Decision List, where each shot annotation
+// becomes an event on a single video track
+type EDL struct {
+	Title  string
+	Rate   *timecode.Rate
+	events []*edlEvent
+	record int64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type edlEvent struct {
+	clip     string
+	srcIn    int64
+	srcOut   int64
+	recIn    int64
+	recOut   int64
+	comments []string
+}
+
+type edlLabel struct {
+	description string
+	confidence  float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Reel name used for all events, the clip name is written as a comment
+	edl_REEL = "AX"
+	// Maximum number of events in a CMX 3600 list
+	edl_MAX_EVENTS = 999
+)
+
+var (
+	ErrTooManyEvents = errors.New("Too many events for an edit decision list")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewEDL returns an empty edit decision list with timecodes at a frame rate
+func NewEDL(title string, rate *timecode.Rate) *EDL {
+	return &EDL{
+		Title:  title,
+		Rate:   rate,
+		events: make([]*edlEvent, 0),
+	}
+}
+
+// AddAnnotations appends an event for every shot in the annotations, with
+// source timecodes relative to the start of the clip and record timecodes
+// following on from the previous event. The labels which overlap each shot
+// are added as comments
+func (this *EDL) AddAnnotations(uri string, annotations *service.Annotations) error {
+	clip := path.Base(uri)
+	for _, shot := range annotations.Shots {
+		srcIn, srcOut := this.Rate.ShotFrames(shot)
+		if srcOut <= srcIn {
+			continue
+		}
+		if len(this.events) >= edl_MAX_EVENTS {
+			return ErrTooManyEvents
+		}
+		this.events = append(this.events, &edlEvent{
+			clip:     clip,
+			srcIn:    srcIn,
+			srcOut:   srcOut,
+			recIn:    this.record,
+			recOut:   this.record + srcOut - srcIn,
+			comments: shotLabels(shot, annotations),
+		})
+		this.record += srcOut - srcIn
+	}
+	return nil
+}
+
+// Write outputs the edit decision list
+func (this *EDL) Write(w io.Writer) error {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "TITLE: %v\r\n", edlSanitize(this.Title))
+	if this.Rate.DropFrame() {
+		fmt.Fprint(&buf, "FCM: DROP FRAME\r\n")
+	} else {
+		fmt.Fprint(&buf, "FCM: NON-DROP FRAME\r\n")
+	}
+	for i, event := range this.events {
+		fmt.Fprintf(&buf, "\r\n%03d  %-8s %-5s %-8s %v %v %v %v\r\n", i+1, edl_REEL, "V", "C",
+			this.Rate.Format(event.srcIn), this.Rate.Format(event.srcOut),
+			this.Rate.Format(event.recIn), this.Rate.Format(event.recOut))
+		fmt.Fprintf(&buf, "* FROM CLIP NAME: %v\r\n", edlSanitize(event.clip))
+		for _, comment := range event.comments {
+			fmt.Fprintf(&buf, "* COMMENT: %v\r\n", edlSanitize(comment))
+		}
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// shotLabels returns descriptions for shot and segment labels which overlap
+// a shot, highest confidence first
+func shotLabels(shot *service.ShotAnnotation, annotations *service.Annotations) []string {
+	labels := make(map[string]*edlLabel)
+	for _, group := range [][]*service.EntityAnnotation{annotations.ShotLabels, annotations.SegmentLabels} {
+		for _, label := range group {
+			if label.Entity == nil {
+				continue
+			}
+			for _, segment := range label.Segments {
+				if segment.StartOffset >= shot.EndOffset || segment.EndOffset <= shot.StartOffset {
+					continue
+				}
+				if existing, exists := labels[label.Entity.Description]; exists == false || existing.confidence < segment.Confidence {
+					labels[label.Entity.Description] = &edlLabel{label.Entity.Description, segment.Confidence}
+				}
+			}
+		}
+	}
+	sorted := make([]*edlLabel, 0, len(labels))
+	for _, label := range labels {
+		sorted = append(sorted, label)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].confidence == sorted[j].confidence {
+			return sorted[i].description < sorted[j].description
+		}
+		return sorted[i].confidence > sorted[j].confidence
+	})
+	comments := make([]string, len(sorted))
+	for i, label := range sorted {
+		comments[i] = fmt.Sprintf("%v (%.2f)", label.description, label.confidence)
+	}
+	return comments
+}
+
+// edlSanitize removes characters which would break the line structure
+func edlSanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, value)
+}
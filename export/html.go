@@ -0,0 +1,300 @@
+package export
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+	"github.com/djthorpe/VideoIntelligence/timecode"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// HTML defines a self-contained report, with a timeline and label table for
+// each video which are rendered in the browser from the embedded annotations.
+// Offsets are shown as timecodes when the rate is set, or seconds otherwise
+type HTML struct {
+	Title  string
+	Rate   *timecode.Rate
+	videos []*htmlVideo
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type htmlVideo struct {
+	Uri         string
+	Annotations *service.Annotations
+}
+
+type htmlRate struct {
+	FrameRate float64
+	DropFrame bool
+}
+
+type htmlData struct {
+	Rate   *htmlRate
+	Videos []*htmlVideo
+}
+
+type htmlReport struct {
+	Title string
+	Data  template.JS
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewHTML returns an empty report, the rate can be nil
+func NewHTML(title string, rate *timecode.Rate) *HTML {
+	return &HTML{
+		Title:  title,
+		Rate:   rate,
+		videos: make([]*htmlVideo, 0),
+	}
+}
+
+// AddAnnotations appends a video to the report
+func (this *HTML) AddAnnotations(uri string, annotations *service.Annotations) error {
+	this.videos = append(this.videos, &htmlVideo{uri, annotations})
+	return nil
+}
+
+// Write outputs the report
+func (this *HTML) Write(w io.Writer) error {
+	// The JSON encoder escapes <, > and & so the data is safe to embed
+	// within a script element
+	report := &htmlData{Videos: this.videos}
+	if this.Rate != nil {
+		report.Rate = &htmlRate{this.Rate.FrameRate(), this.Rate.DropFrame()}
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return html_template.Execute(w, &htmlReport{this.Title, template.JS(data)})
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TEMPLATE
+
+var html_template = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h2 { font-size: 1.1em; margin-top: 2em; word-break: break-all; }
+svg { display: block; margin: 1em 0; }
+svg text { font-size: 11px; fill: #444; }
+svg .axis line { stroke: #ccc; }
+svg rect:hover { stroke: #000; stroke-width: 1; }
+table { border-collapse: collapse; font-size: 0.9em; }
+th, td { padding: 0.3em 0.8em; border-bottom: 1px solid #eee; text-align: left; }
+th { cursor: pointer; user-select: none; background: #f6f6f6; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.number { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<div id="report"></div>
+<script id="annotations" type="application/json">{{ .Data }}</script>
+<script>
+(function() {
+	"use strict";
+	var NS = "http://www.w3.org/2000/svg";
+	var LIKELIHOOD = ["LIKELIHOOD_UNSPECIFIED", "LIKELIHOOD_VERY_UNLIKELY", "LIKELIHOOD_UNLIKELY", "LIKELIHOOD_POSSIBLE", "LIKELIHOOD_LIKELY", "LIKELIHOOD_VERY_LIKELY"];
+	var LIKELIHOOD_COLOR = ["#ddd", "#2e7d32", "#9ccc65", "#fdd835", "#fb8c00", "#c62828"];
+	var EXPLICIT = [["Likelihood", "explicit content"], ["Spoof", "spoof"], ["Medical", "medical"], ["Violent", "violent"], ["Racy", "racy"]];
+	var LABEL_WIDTH = 200, WIDTH = 1000, ROW = 16;
+
+	var data = JSON.parse(document.getElementById("annotations").textContent) || {};
+
+	function seconds(ns) { return ns / 1e9; }
+	function pad(n) { return n < 10 ? "0" + n : "" + n; }
+
+	// Returns a SMPTE timecode for the nearest frame
+	function timecode(ns, rate) {
+		var nominal = Math.ceil(rate.FrameRate - 0.01);
+		var frame = Math.floor(seconds(ns) * rate.FrameRate + 0.5), sep = ":";
+		if (rate.DropFrame) {
+			var drop = nominal / 15, perMinute = nominal * 60 - drop, perTenMinutes = nominal * 600 - drop * 9;
+			var tens = Math.floor(frame / perTenMinutes), rem = frame % perTenMinutes;
+			frame += drop * 9 * tens;
+			if (rem >= drop) { frame += drop * Math.floor((rem - drop) / perMinute); }
+			sep = ";";
+		}
+		return pad(Math.floor(frame / (nominal * 3600))) + ":" + pad(Math.floor(frame / (nominal * 60)) % 60) + ":" +
+			pad(Math.floor(frame / nominal) % 60) + sep + pad(frame % nominal);
+	}
+
+	function duration(ns) {
+		return data.Rate ? timecode(ns, data.Rate) : seconds(ns).toFixed(3) + "s";
+	}
+
+	function el(ns, name, attrs, parent) {
+		var e = ns ? document.createElementNS(ns, name) : document.createElement(name);
+		for (var k in attrs) { e.setAttribute(k, attrs[k]); }
+		if (parent) { parent.appendChild(e); }
+		return e;
+	}
+
+	function text(parent, value) {
+		parent.appendChild(document.createTextNode(value));
+		return parent;
+	}
+
+	function tooltip(parent, lines) {
+		text(el(NS, "title", {}, parent), lines.join("\n"));
+	}
+
+	// Collect labels by entity, summing the duration over all segments
+	function labels(annotations) {
+		var byEntity = {}, result = [];
+		[["shot_label", annotations.ShotLabels], ["segment_label", annotations.SegmentLabels]].forEach(function(group) {
+			(group[1] || []).forEach(function(label) {
+				var entity = label.Entity || {};
+				var key = group[0] + ":" + entity.EntityId;
+				var row = byEntity[key];
+				if (!row) {
+					row = byEntity[key] = {
+						type: group[0],
+						description: entity.Description || "",
+						entity: entity.EntityId || "",
+						categories: (label.Categories || []).map(function(c) { return c.Description; }).join(", "),
+						segments: [], total: 0, confidence: 0
+					};
+					result.push(row);
+				}
+				(label.Segments || []).forEach(function(segment) {
+					row.segments.push(segment);
+					row.total += segment.EndOffset - segment.StartOffset;
+					row.confidence = Math.max(row.confidence, segment.Confidence);
+				});
+			});
+		});
+		return result.sort(function(a, b) { return b.total - a.total; });
+	}
+
+	function end(annotations, rows) {
+		var max = 0;
+		(annotations.Shots || []).forEach(function(shot) { max = Math.max(max, shot.EndOffset); });
+		rows.forEach(function(row) { row.segments.forEach(function(segment) { max = Math.max(max, segment.EndOffset); }); });
+		(annotations.ExplicitContent || []).forEach(function(frame) { max = Math.max(max, frame.Offset); });
+		return max || 1;
+	}
+
+	function timeline(parent, annotations, rows) {
+		var total = end(annotations, rows);
+		var explicit = annotations.ExplicitContent || [];
+		var dimensions = EXPLICIT.filter(function(d) { return explicit.some(function(frame) { return frame[d[0]]; }); });
+		var lanes = rows.length + 1 + dimensions.length;
+		var svg = el(NS, "svg", { width: LABEL_WIDTH + WIDTH, height: (lanes + 1) * ROW + 10 }, parent);
+		function x(ns) { return LABEL_WIDTH + WIDTH * ns / total; }
+		function lane(i, name) {
+			text(el(NS, "text", { x: 0, y: i * ROW + ROW - 4 }, svg), name.length > 30 ? name.substr(0, 29) + "…" : name);
+			return i * ROW + 2;
+		}
+		function bar(y, start, stop, color, lines) {
+			var r = el(NS, "rect", { x: x(start), y: y, width: Math.max(1, x(stop) - x(start)), height: ROW - 4, fill: color }, svg);
+			tooltip(r, lines);
+		}
+
+		// Axis
+		var axis = el(NS, "g", { "class": "axis" }, svg);
+		for (var t = 0; t <= 10; t++) {
+			var ns = total * t / 10, y = lanes * ROW;
+			el(NS, "line", { x1: x(ns), x2: x(ns), y1: 0, y2: y }, axis);
+			text(el(NS, "text", { x: x(ns), y: y + ROW, "text-anchor": t == 10 ? "end" : "start" }, axis), duration(ns));
+		}
+
+		// Shots
+		var y = lane(0, "shots");
+		(annotations.Shots || []).forEach(function(shot, i) {
+			bar(y, shot.StartOffset, shot.EndOffset, i % 2 ? "#5c6bc0" : "#9fa8da", ["shot " + (i + 1), duration(shot.StartOffset) + " – " + duration(shot.EndOffset)]);
+		});
+
+		// Labels, longest total duration first
+		rows.forEach(function(row, i) {
+			var y = lane(i + 1, row.description);
+			row.segments.forEach(function(segment) {
+				bar(y, segment.StartOffset, segment.EndOffset, row.type == "shot_label" ? "#8e24aa" : "#1e88e5", [
+					row.description + " (" + row.type + ")",
+					"entity: " + row.entity,
+					"categories: " + (row.categories || "none"),
+					"confidence: " + segment.Confidence.toFixed(3),
+					duration(segment.StartOffset) + " – " + duration(segment.EndOffset)
+				]);
+			});
+		});
+
+		// Explicit content likelihood for each type of explicit content, each
+		// frame extends to the next
+		dimensions.forEach(function(d, j) {
+			var y = lane(rows.length + 1 + j, d[1]);
+			explicit.forEach(function(frame, i) {
+				var stop = i + 1 < explicit.length ? explicit[i + 1].Offset : total;
+				var likelihood = frame[d[0]] || 0;
+				bar(y, frame.Offset, stop, LIKELIHOOD_COLOR[likelihood] || LIKELIHOOD_COLOR[0], [d[1], LIKELIHOOD[likelihood] || LIKELIHOOD[0], duration(frame.Offset)]);
+			});
+		});
+	}
+
+	function table(parent, rows) {
+		var columns = [
+			{ name: "Type", value: function(r) { return r.type; } },
+			{ name: "Label", value: function(r) { return r.description; } },
+			{ name: "Entity", value: function(r) { return r.entity; } },
+			{ name: "Categories", value: function(r) { return r.categories; } },
+			{ name: "Segments", value: function(r) { return r.segments.length; }, number: true },
+			{ name: "Duration", value: function(r) { return r.total; }, format: duration, number: true },
+			{ name: "Confidence", value: function(r) { return r.confidence; }, format: function(v) { return v.toFixed(3); }, number: true }
+		];
+		var t = el(null, "table", {}, parent);
+		var head = el(null, "tr", {}, el(null, "thead", {}, t));
+		var body = el(null, "tbody", {}, t);
+		var sorted = { column: 5, desc: true };
+		function render() {
+			var c = columns[sorted.column];
+			rows.sort(function(a, b) {
+				var va = c.value(a), vb = c.value(b);
+				var cmp = va < vb ? -1 : va > vb ? 1 : 0;
+				return sorted.desc ? -cmp : cmp;
+			});
+			head.childNodes.forEach(function(th, i) { th.className = i == sorted.column ? (sorted.desc ? "desc" : "asc") : ""; });
+			body.innerHTML = "";
+			rows.forEach(function(row) {
+				var tr = el(null, "tr", {}, body);
+				columns.forEach(function(c) {
+					var v = c.value(row);
+					text(el(null, "td", c.number ? { "class": "number" } : {}, tr), c.format ? c.format(v) : v);
+				});
+			});
+		}
+		columns.forEach(function(c, i) {
+			text(el(null, "th", {}, head), c.name).addEventListener("click", function() {
+				sorted = { column: i, desc: sorted.column == i ? !sorted.desc : !!c.number };
+				render();
+			});
+		});
+		render();
+	}
+
+	var report = document.getElementById("report");
+	(data.Videos || []).forEach(function(video) {
+		var annotations = video.Annotations || {};
+		var rows = labels(annotations);
+		text(el(null, "h2", {}, report), video.Uri);
+		timeline(report, annotations, rows);
+		table(report, rows.slice());
+	});
+})();
+</script>
+</body>
+</html>
+`))
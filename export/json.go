@@ -0,0 +1,52 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// JSON defines a document with the annotations for each video, where
+// offsets are in nanoseconds
+type JSON struct {
+	videos []*jsonVideo
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type jsonDocument struct {
+	Videos []*jsonVideo
+}
+
+type jsonVideo struct {
+	Uri         string
+	Annotations *service.Annotations
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewJSON returns an empty document
+func NewJSON() *JSON {
+	return &JSON{
+		videos: make([]*jsonVideo, 0),
+	}
+}
+
+// AddAnnotations appends a video to the document
+func (this *JSON) AddAnnotations(uri string, annotations *service.Annotations) error {
+	this.videos = append(this.videos, &jsonVideo{uri, annotations})
+	return nil
+}
+
+// Write outputs the document
+func (this *JSON) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&jsonDocument{this.videos})
+}
@@ -0,0 +1,299 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+	"github.com/djthorpe/VideoIntelligence/timecode"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// OTIO defines an OpenTimelineIO timeline, where each shot annotation becomes
+// a clip on a single video track, shot labels become markers on the clips, and
+// segment labels and explicit content frames become markers on the track
+type OTIO struct {
+	Name     string
+	Rate     *timecode.Rate
+	children []interface{}
+	markers  []*otioMarker
+	record   time.Duration
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type otioRationalTime struct {
+	Schema string  `json:"OTIO_SCHEMA"`
+	Rate   float64 `json:"rate"`
+	Value  float64 `json:"value"`
+}
+
+type otioTimeRange struct {
+	Schema    string            `json:"OTIO_SCHEMA"`
+	StartTime *otioRationalTime `json:"start_time"`
+	Duration  *otioRationalTime `json:"duration"`
+}
+
+type otioMarker struct {
+	Schema      string                 `json:"OTIO_SCHEMA"`
+	Name        string                 `json:"name"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	Color       string                 `json:"color"`
+	MarkedRange *otioTimeRange         `json:"marked_range"`
+	Comment     string                 `json:"comment"`
+}
+
+type otioExternalReference struct {
+	Schema         string                 `json:"OTIO_SCHEMA"`
+	Name           string                 `json:"name"`
+	Metadata       map[string]interface{} `json:"metadata"`
+	AvailableRange *otioTimeRange         `json:"available_range"`
+	TargetUrl      string                 `json:"target_url"`
+}
+
+type otioClip struct {
+	Schema         string                 `json:"OTIO_SCHEMA"`
+	Name           string                 `json:"name"`
+	Metadata       map[string]interface{} `json:"metadata"`
+	SourceRange    *otioTimeRange         `json:"source_range"`
+	Effects        []interface{}          `json:"effects"`
+	Markers        []*otioMarker          `json:"markers"`
+	Enabled        bool                   `json:"enabled"`
+	MediaReference *otioExternalReference `json:"media_reference"`
+}
+
+type otioGap struct {
+	Schema      string                 `json:"OTIO_SCHEMA"`
+	Name        string                 `json:"name"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	SourceRange *otioTimeRange         `json:"source_range"`
+	Effects     []interface{}          `json:"effects"`
+	Markers     []*otioMarker          `json:"markers"`
+	Enabled     bool                   `json:"enabled"`
+}
+
+type otioTrack struct {
+	Schema      string                 `json:"OTIO_SCHEMA"`
+	Name        string                 `json:"name"`
+	Metadata    map[string]interface{} `json:"metadata"`
+	SourceRange *otioTimeRange         `json:"source_range"`
+	Effects     []interface{}          `json:"effects"`
+	Markers     []*otioMarker          `json:"markers"`
+	Enabled     bool                   `json:"enabled"`
+	Children    []interface{}          `json:"children"`
+	Kind        string                 `json:"kind,omitempty"`
+}
+
+type otioTimeline struct {
+	Schema          string                 `json:"OTIO_SCHEMA"`
+	Name            string                 `json:"name"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	GlobalStartTime *otioRationalTime      `json:"global_start_time"`
+	Tracks          *otioTrack             `json:"tracks"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Namespace for metadata added to markers and clips
+	otio_METADATA_NAMESPACE = "videointelligence"
+)
+
+var (
+	otio_likelihood_color = map[service.LikelihoodType]string{
+		service.LIKELIHOOD_UNSPECIFIED:   "WHITE",
+		service.LIKELIHOOD_VERY_UNLIKELY: "GREEN",
+		service.LIKELIHOOD_UNLIKELY:      "CYAN",
+		service.LIKELIHOOD_POSSIBLE:      "YELLOW",
+		service.LIKELIHOOD_LIKELY:        "ORANGE",
+		service.LIKELIHOOD_VERY_LIKELY:   "RED",
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewOTIO returns an empty timeline with a frame rate
+func NewOTIO(name string, rate *timecode.Rate) *OTIO {
+	return &OTIO{
+		Name:     name,
+		Rate:     rate,
+		children: make([]interface{}, 0),
+		markers:  make([]*otioMarker, 0),
+	}
+}
+
+// AddAnnotations appends clips for every shot in the annotations, following
+// on from the previous video. When there are no shots, a single clip is added
+// which covers all the annotations
+func (this *OTIO) AddAnnotations(uri string, annotations *service.Annotations) error {
+	shots := annotations.Shots
+	if len(shots) == 0 {
+		shots = []*service.ShotAnnotation{&service.ShotAnnotation{EndOffset: annotations.Duration()}}
+	}
+
+	// Add clips and gaps between them
+	start, position := this.record, time.Duration(0)
+	for i, shot := range shots {
+		if shot.EndOffset <= shot.StartOffset {
+			continue
+		}
+		if shot.StartOffset > position {
+			this.children = append(this.children, this.newGap(shot.StartOffset-position))
+		}
+		clip := this.newClip(fmt.Sprintf("%v shot %v", path.Base(uri), i+1), uri, shot)
+		for _, label := range annotations.ShotLabels {
+			for _, segment := range label.Segments {
+				if segment.StartOffset >= shot.EndOffset || segment.EndOffset <= shot.StartOffset {
+					continue
+				}
+				clip.Markers = append(clip.Markers, this.newLabelMarker("shot_label", "PURPLE", label, segment, 0))
+			}
+		}
+		this.children = append(this.children, clip)
+		position = shot.EndOffset
+	}
+
+	// Add track markers, which are relative to the start of the track
+	for _, label := range annotations.SegmentLabels {
+		for _, segment := range label.Segments {
+			this.markers = append(this.markers, this.newLabelMarker("segment_label", "BLUE", label, segment, start))
+		}
+	}
+	dimensions := annotations.ExplicitDimensions()
+	for _, frame := range annotations.ExplicitContent {
+		metadata := map[string]interface{}{
+			"type":       "explicit_content",
+			"likelihood": frame.Likelihood.String(),
+		}
+		// Other types of explicit content are keyed by name, such as violent
+		for _, dimension := range dimensions {
+			if dimension != service.EXPLICIT_ADULT {
+				metadata[strings.ToLower(strings.TrimPrefix(dimension.String(), "EXPLICIT_"))] = frame.Get(dimension).String()
+			}
+		}
+		this.markers = append(this.markers, &otioMarker{
+			Schema: "Marker.2",
+			Name:   frame.Likelihood.String(),
+			Metadata: map[string]interface{}{
+				otio_METADATA_NAMESPACE: metadata,
+			},
+			Color:       otio_likelihood_color[frame.Likelihood],
+			MarkedRange: this.newTimeRange(start+frame.Offset, 0),
+		})
+	}
+
+	// Set the position for the next video
+	this.record = start + position
+	return nil
+}
+
+// Write outputs the timeline as JSON
+func (this *OTIO) Write(w io.Writer) error {
+	timeline := &otioTimeline{
+		Schema:   "Timeline.1",
+		Name:     this.Name,
+		Metadata: make(map[string]interface{}),
+		Tracks: &otioTrack{
+			Schema:   "Stack.1",
+			Name:     "tracks",
+			Metadata: make(map[string]interface{}),
+			Effects:  make([]interface{}, 0),
+			Markers:  make([]*otioMarker, 0),
+			Enabled:  true,
+			Children: []interface{}{
+				&otioTrack{
+					Schema:   "Track.1",
+					Name:     "V1",
+					Metadata: make(map[string]interface{}),
+					Effects:  make([]interface{}, 0),
+					Markers:  this.markers,
+					Enabled:  true,
+					Children: this.children,
+					Kind:     "Video",
+				},
+			},
+		},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(timeline)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (this *OTIO) newRationalTime(value time.Duration) *otioRationalTime {
+	return &otioRationalTime{"RationalTime.1", this.Rate.FrameRate(), value.Seconds() * this.Rate.FrameRate()}
+}
+
+func (this *OTIO) newTimeRange(start, duration time.Duration) *otioTimeRange {
+	return &otioTimeRange{"TimeRange.1", this.newRationalTime(start), this.newRationalTime(duration)}
+}
+
+func (this *OTIO) newGap(duration time.Duration) *otioGap {
+	return &otioGap{
+		Schema:      "Gap.1",
+		Metadata:    make(map[string]interface{}),
+		SourceRange: this.newTimeRange(0, duration),
+		Effects:     make([]interface{}, 0),
+		Markers:     make([]*otioMarker, 0),
+		Enabled:     true,
+	}
+}
+
+func (this *OTIO) newClip(name, uri string, shot *service.ShotAnnotation) *otioClip {
+	return &otioClip{
+		Schema:      "Clip.1",
+		Name:        name,
+		Metadata:    make(map[string]interface{}),
+		SourceRange: this.newTimeRange(shot.StartOffset, shot.EndOffset-shot.StartOffset),
+		Effects:     make([]interface{}, 0),
+		Markers:     make([]*otioMarker, 0),
+		Enabled:     true,
+		MediaReference: &otioExternalReference{
+			Schema:    "ExternalReference.1",
+			Name:      path.Base(uri),
+			Metadata:  make(map[string]interface{}),
+			TargetUrl: uri,
+		},
+	}
+}
+
+func (this *OTIO) newLabelMarker(t, color string, label *service.EntityAnnotation, segment *service.Segment, offset time.Duration) *otioMarker {
+	metadata := map[string]interface{}{
+		"type":       t,
+		"confidence": segment.Confidence,
+	}
+	name := ""
+	if label.Entity != nil {
+		name = label.Entity.Description
+		metadata["entity_id"] = label.Entity.EntityId
+		metadata["description"] = label.Entity.Description
+		metadata["language_code"] = label.Entity.LanguageCode
+	}
+	categories := make([]map[string]string, 0, len(label.Categories))
+	for _, category := range label.Categories {
+		categories = append(categories, map[string]string{
+			"entity_id":   category.EntityId,
+			"description": category.Description,
+		})
+	}
+	metadata["categories"] = categories
+	return &otioMarker{
+		Schema:      "Marker.2",
+		Name:        name,
+		Metadata:    map[string]interface{}{otio_METADATA_NAMESPACE: metadata},
+		Color:       color,
+		MarkedRange: this.newTimeRange(offset+segment.StartOffset, segment.EndOffset-segment.StartOffset),
+		Comment:     fmt.Sprintf("%v (%.2f)", name, segment.Confidence),
+	}
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Transcript defines a plain text transcript of the speech in each video.
+// When Dialogue is set, each change of speaker starts a new line which is
+// labelled with the speaker
+type Transcript struct {
+	Dialogue bool
+	videos   []*transcriptVideo
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type transcriptVideo struct {
+	uri        string
+	paragraphs []string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewTranscript returns an empty transcript
+func NewTranscript(dialogue bool) *Transcript {
+	return &Transcript{
+		Dialogue: dialogue,
+		videos:   make([]*transcriptVideo, 0),
+	}
+}
+
+// AddAnnotations appends the transcript for a video
+func (this *Transcript) AddAnnotations(uri string, annotations *service.Annotations) error {
+	video := &transcriptVideo{uri, make([]string, 0)}
+	if this.Dialogue {
+		speaker, line := int64(-1), make([]string, 0)
+		for _, word := range annotations.Words() {
+			if word.Speaker != speaker && len(line) > 0 {
+				video.paragraphs = append(video.paragraphs, speakerLine(speaker, line))
+				line = line[:0]
+			}
+			speaker, line = word.Speaker, append(line, word.Word)
+		}
+		if len(line) > 0 {
+			video.paragraphs = append(video.paragraphs, speakerLine(speaker, line))
+		}
+	} else {
+		for _, transcription := range annotations.Speech {
+			if len(transcription.Alternatives) == 0 {
+				continue
+			}
+			if transcript := strings.TrimSpace(transcription.Alternatives[0].Transcript); transcript != "" {
+				video.paragraphs = append(video.paragraphs, transcript)
+			}
+		}
+	}
+	this.videos = append(this.videos, video)
+	return nil
+}
+
+// Write outputs the transcript, with a heading for each video when there is
+// more than one
+func (this *Transcript) Write(w io.Writer) error {
+	// Paragraphs are separated by a blank line, and lines of dialogue are not
+	separator := "\n\n"
+	if this.Dialogue {
+		separator = "\n"
+	}
+	var buf strings.Builder
+	for i, video := range this.videos {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if len(this.videos) > 1 {
+			fmt.Fprintf(&buf, "# %v\n\n", video.uri)
+		}
+		if len(video.paragraphs) > 0 {
+			fmt.Fprintln(&buf, strings.Join(video.paragraphs, separator))
+		}
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// speakerLine returns a line of dialogue, labelled with the speaker when
+// known
+func speakerLine(speaker int64, words []string) string {
+	if speaker > 0 {
+		return fmt.Sprintf("Speaker %v: %v", speaker, strings.Join(words, " "))
+	}
+	return strings.Join(words, " ")
+}
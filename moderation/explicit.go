@@ -0,0 +1,185 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// ExplicitConfig defines how explicit content frames are turned into ranges,
+// for one type of explicit content (adult content by default). A range
+// starts at a frame with a likelihood of at least Enter, and continues until
+// a frame with a likelihood below Exit. Ranges separated by no more than
+// MergeGap are joined, and then ranges shorter than MinDuration are removed
+type ExplicitConfig struct {
+	Dimension   service.ExplicitDimension
+	Enter       service.LikelihoodType
+	Exit        service.LikelihoodType
+	MinDuration time.Duration
+	MergeGap    time.Duration
+}
+
+// ExplicitRange is a flagged range, with the highest likelihood and the
+// number of frames within the range
+type ExplicitRange struct {
+	Start  time.Duration
+	End    time.Duration
+	Peak   service.LikelihoodType
+	Frames int
+}
+
+// ExplicitSummary contains the flagged ranges for a video, and the total
+// flagged duration as a duration and as a percentage of the runtime
+type ExplicitSummary struct {
+	Ranges  []*ExplicitRange
+	Frames  int
+	Flagged time.Duration
+	Runtime time.Duration
+	Percent float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Interval between frames when it can't be determined from the frames
+	explicit_FRAME_INTERVAL = time.Second
+)
+
+var (
+	// DefaultExplicitConfig flags ranges which reach LIKELY, until the
+	// likelihood drops below POSSIBLE
+	DefaultExplicitConfig = &ExplicitConfig{
+		Enter:    service.LIKELIHOOD_LIKELY,
+		Exit:     service.LIKELIHOOD_POSSIBLE,
+		MergeGap: explicit_FRAME_INTERVAL,
+	}
+)
+
+var (
+	ErrInvalidThreshold = errors.New("Invalid likelihood threshold")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// AnalyseExplicit returns the flagged ranges for explicit content frames,
+// using the default configuration when config is nil. Frames are treated as
+// lasting until the next frame, based on the typical interval between frames
+func AnalyseExplicit(annotations *service.Annotations, config *ExplicitConfig) (*ExplicitSummary, error) {
+	if config == nil {
+		config = DefaultExplicitConfig
+	}
+	if config.Enter == service.LIKELIHOOD_UNSPECIFIED || config.Exit == service.LIKELIHOOD_UNSPECIFIED || config.Exit > config.Enter {
+		return nil, ErrInvalidThreshold
+	}
+
+	// Order the frames by offset
+	frames := make([]*service.ExplicitContentAnnotation, len(annotations.ExplicitContent))
+	copy(frames, annotations.ExplicitContent)
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].Offset < frames[j].Offset
+	})
+	interval := frameInterval(frames)
+
+	// Flag ranges with hysteresis
+	ranges := make([]*ExplicitRange, 0)
+	var current *ExplicitRange
+	for _, frame := range frames {
+		likelihood := frame.Get(config.Dimension)
+		if current == nil && likelihood >= config.Enter {
+			current = &ExplicitRange{Start: frame.Offset}
+			ranges = append(ranges, current)
+		} else if current != nil && likelihood < config.Exit {
+			current = nil
+		}
+		if current != nil {
+			current.End = frame.Offset + interval
+			current.Frames += 1
+			if likelihood > current.Peak {
+				current.Peak = likelihood
+			}
+		}
+	}
+
+	// Merge ranges with small gaps, then remove short ranges
+	summary := &ExplicitSummary{Ranges: make([]*ExplicitRange, 0, len(ranges))}
+	for _, r := range ranges {
+		if last := len(summary.Ranges) - 1; last >= 0 && r.Start-summary.Ranges[last].End <= config.MergeGap {
+			summary.Ranges[last].End = r.End
+			summary.Ranges[last].Frames += r.Frames
+			if r.Peak > summary.Ranges[last].Peak {
+				summary.Ranges[last].Peak = r.Peak
+			}
+		} else {
+			summary.Ranges = append(summary.Ranges, r)
+		}
+	}
+	filtered := summary.Ranges[:0]
+	for _, r := range summary.Ranges {
+		if r.Duration() >= config.MinDuration {
+			filtered = append(filtered, r)
+			summary.Frames += r.Frames
+			summary.Flagged += r.Duration()
+		}
+	}
+	summary.Ranges = filtered
+
+	// Determine the runtime, which includes the last frame
+	summary.Runtime = annotations.Duration()
+	if len(frames) > 0 && frames[len(frames)-1].Offset+interval > summary.Runtime {
+		summary.Runtime = frames[len(frames)-1].Offset + interval
+	}
+	if summary.Runtime > 0 {
+		summary.Percent = 100 * float64(summary.Flagged) / float64(summary.Runtime)
+	}
+	return summary, nil
+}
+
+// Dimensions returns the types of explicit content which have a likelihood
+// for at least one frame, in order
+func Dimensions(annotations *service.Annotations) []service.ExplicitDimension {
+	return annotations.ExplicitDimensions()
+}
+
+// Duration returns the length of the range
+func (this *ExplicitRange) Duration() time.Duration {
+	return this.End - this.Start
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// frameInterval returns the median interval between frames
+func frameInterval(frames []*service.ExplicitContentAnnotation) time.Duration {
+	intervals := make([]time.Duration, 0, len(frames))
+	for i := 1; i < len(frames); i++ {
+		if interval := frames[i].Offset - frames[i-1].Offset; interval > 0 {
+			intervals = append(intervals, interval)
+		}
+	}
+	if len(intervals) == 0 {
+		return explicit_FRAME_INTERVAL
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i] < intervals[j]
+	})
+	return intervals[len(intervals)/2]
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (r *ExplicitRange) String() string {
+	return fmt.Sprintf("<ExplicitRange start=%v end=%v peak=%v frames=%v>", r.Start, r.End, r.Peak, r.Frames)
+}
+
+func (s *ExplicitSummary) String() string {
+	return fmt.Sprintf("<ExplicitSummary ranges=%v frames=%v flagged=%v runtime=%v percent=%.1f%%>", len(s.Ranges), s.Frames, s.Flagged, s.Runtime, s.Percent)
+}
@@ -0,0 +1,163 @@
+package moderation
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestAnalyseExplicit(t *testing.T) {
+	annotations := explicitFrames(1, 2, 4, 3, 3, 2, 1, 5, 1, 1, 1, 4, 4, 3, 1)
+	tests := []struct {
+		name    string
+		config  *ExplicitConfig
+		ranges  []*ExplicitRange
+		flagged time.Duration
+	}{
+		{"default", nil, []*ExplicitRange{
+			{2 * time.Second, 5 * time.Second, service.LIKELIHOOD_LIKELY, 3},
+			{7 * time.Second, 8 * time.Second, service.LIKELIHOOD_VERY_LIKELY, 1},
+			{11 * time.Second, 14 * time.Second, service.LIKELIHOOD_LIKELY, 3},
+		}, 7 * time.Second},
+		{"min duration", &ExplicitConfig{Enter: service.LIKELIHOOD_LIKELY, Exit: service.LIKELIHOOD_POSSIBLE, MinDuration: 2 * time.Second}, []*ExplicitRange{
+			{2 * time.Second, 5 * time.Second, service.LIKELIHOOD_LIKELY, 3},
+			{11 * time.Second, 14 * time.Second, service.LIKELIHOOD_LIKELY, 3},
+		}, 6 * time.Second},
+		{"merge gap", &ExplicitConfig{Enter: service.LIKELIHOOD_LIKELY, Exit: service.LIKELIHOOD_POSSIBLE, MergeGap: 5 * time.Second}, []*ExplicitRange{
+			{2 * time.Second, 14 * time.Second, service.LIKELIHOOD_VERY_LIKELY, 7},
+		}, 12 * time.Second},
+		{"no hysteresis", &ExplicitConfig{Enter: service.LIKELIHOOD_LIKELY, Exit: service.LIKELIHOOD_LIKELY}, []*ExplicitRange{
+			{2 * time.Second, 3 * time.Second, service.LIKELIHOOD_LIKELY, 1},
+			{7 * time.Second, 8 * time.Second, service.LIKELIHOOD_VERY_LIKELY, 1},
+			{11 * time.Second, 13 * time.Second, service.LIKELIHOOD_LIKELY, 2},
+		}, 4 * time.Second},
+	}
+	for _, test := range tests {
+		summary, err := AnalyseExplicit(annotations, test.config)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if len(summary.Ranges) != len(test.ranges) {
+			t.Errorf("%v: Expected %v ranges, got %v", test.name, len(test.ranges), summary.Ranges)
+			continue
+		}
+		frames := 0
+		for i, r := range summary.Ranges {
+			if *r != *test.ranges[i] {
+				t.Errorf("%v: Expected %v, got %v", test.name, test.ranges[i], r)
+			}
+			frames += r.Frames
+		}
+		if summary.Frames != frames || summary.Flagged != test.flagged {
+			t.Errorf("%v: Unexpected summary %v", test.name, summary)
+		}
+		// The runtime includes the interval after the last frame
+		if summary.Runtime != 15*time.Second {
+			t.Errorf("%v: Unexpected runtime %v", test.name, summary.Runtime)
+		}
+		if percent := 100 * test.flagged.Seconds() / 15; math.Abs(summary.Percent-percent) > 0.001 {
+			t.Errorf("%v: Expected %.1f%%, got %.1f%%", test.name, percent, summary.Percent)
+		}
+	}
+}
+
+func TestAnalyseExplicitUnordered(t *testing.T) {
+	annotations := explicitFrames(1, 4, 4, 1)
+	annotations.ExplicitContent[0], annotations.ExplicitContent[2] = annotations.ExplicitContent[2], annotations.ExplicitContent[0]
+	summary, err := AnalyseExplicit(annotations, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Ranges) != 1 || summary.Ranges[0].Start != time.Second || summary.Ranges[0].End != 3*time.Second {
+		t.Errorf("Unexpected ranges %v", summary.Ranges)
+	}
+	if annotations.ExplicitContent[0].Offset != 2*time.Second {
+		t.Error("Unexpected change to the order of frames")
+	}
+}
+
+func TestAnalyseExplicitInterval(t *testing.T) {
+	// Frames every 500ms, with one missing frame
+	annotations := &service.Annotations{}
+	for _, offset := range []time.Duration{0, 500, 1000, 2000, 2500} {
+		annotations.ExplicitContent = append(annotations.ExplicitContent, &service.ExplicitContentAnnotation{
+			Offset:     offset * time.Millisecond,
+			Likelihood: service.LIKELIHOOD_VERY_LIKELY,
+		})
+	}
+	summary, err := AnalyseExplicit(annotations, &ExplicitConfig{Enter: service.LIKELIHOOD_LIKELY, Exit: service.LIKELIHOOD_POSSIBLE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Ranges) != 1 || summary.Ranges[0].End != 3*time.Second || summary.Ranges[0].Frames != 5 {
+		t.Errorf("Unexpected ranges %v", summary.Ranges)
+	}
+	if summary.Runtime != 3*time.Second || summary.Percent != 100 {
+		t.Errorf("Unexpected summary %v", summary)
+	}
+}
+
+func TestAnalyseExplicitDimension(t *testing.T) {
+	annotations := &service.Annotations{ExplicitContent: []*service.ExplicitContentAnnotation{
+		{Offset: 0, Likelihood: service.LIKELIHOOD_UNLIKELY, Violent: service.LIKELIHOOD_VERY_LIKELY},
+		{Offset: time.Second, Likelihood: service.LIKELIHOOD_VERY_UNLIKELY, Violent: service.LIKELIHOOD_VERY_LIKELY},
+	}}
+	if dimensions := Dimensions(annotations); len(dimensions) != 2 || dimensions[0] != service.EXPLICIT_ADULT || dimensions[1] != service.EXPLICIT_VIOLENT {
+		t.Errorf("Unexpected dimensions %v", dimensions)
+	}
+	if summary, err := AnalyseExplicit(annotations, nil); err != nil {
+		t.Error(err)
+	} else if len(summary.Ranges) != 0 || summary.Percent != 0 {
+		t.Errorf("Unexpected adult summary %v", summary)
+	}
+	config := &ExplicitConfig{Dimension: service.EXPLICIT_VIOLENT, Enter: service.LIKELIHOOD_LIKELY, Exit: service.LIKELIHOOD_POSSIBLE}
+	if summary, err := AnalyseExplicit(annotations, config); err != nil {
+		t.Error(err)
+	} else if len(summary.Ranges) != 1 || summary.Flagged != 2*time.Second || summary.Percent != 100 {
+		t.Errorf("Unexpected violent summary %v", summary)
+	}
+}
+
+func TestAnalyseExplicitEmpty(t *testing.T) {
+	summary, err := AnalyseExplicit(&service.Annotations{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Ranges) != 0 || summary.Runtime != 0 || summary.Percent != 0 {
+		t.Errorf("Unexpected summary %v", summary)
+	}
+}
+
+func TestInvalidThreshold(t *testing.T) {
+	tests := []*ExplicitConfig{
+		{Enter: service.LIKELIHOOD_UNLIKELY, Exit: service.LIKELIHOOD_POSSIBLE},
+		{Enter: service.LIKELIHOOD_UNSPECIFIED, Exit: service.LIKELIHOOD_UNSPECIFIED},
+		{Enter: service.LIKELIHOOD_LIKELY, Exit: service.LIKELIHOOD_UNSPECIFIED},
+	}
+	for _, config := range tests {
+		if _, err := AnalyseExplicit(explicitFrames(5), config); err != ErrInvalidThreshold {
+			t.Errorf("%v/%v: Expected %v, got %v", config.Enter, config.Exit, ErrInvalidThreshold, err)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// explicitFrames returns annotations with adult content frames every second
+func explicitFrames(likelihoods ...service.LikelihoodType) *service.Annotations {
+	annotations := &service.Annotations{}
+	for i, likelihood := range likelihoods {
+		annotations.ExplicitContent = append(annotations.ExplicitContent, &service.ExplicitContentAnnotation{
+			Offset:     time.Duration(i) * time.Second,
+			Likelihood: likelihood,
+		})
+	}
+	return annotations
+}
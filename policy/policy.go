@@ -0,0 +1,321 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/moderation"
+	"github.com/djthorpe/VideoIntelligence/segments"
+	"github.com/djthorpe/VideoIntelligence/service"
+	yaml "gopkg.in/yaml.v3"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Policy is a list of rules which are evaluated against annotations. The
+// verdict is the most severe verdict of the rules which match
+type Policy struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule has a verdict and either a label or an explicit content condition
+type Rule struct {
+	Name     string             `yaml:"name"`
+	Verdict  Verdict            `yaml:"verdict"`
+	Label    *LabelCondition    `yaml:"label"`
+	Explicit *ExplicitCondition `yaml:"explicit"`
+}
+
+// LabelCondition matches shot, segment and frame labels by entity identifier
+// or description (ignoring case), including categories. Segments with at
+// least the minimum confidence are merged, and match when they last longer
+// than the minimum duration. Frame labels have no duration, so only match
+// conditions without a minimum duration
+type LabelCondition struct {
+	Entity        string   `yaml:"entity"`
+	MinConfidence float64  `yaml:"min_confidence"`
+	MinDuration   Duration `yaml:"min_duration"`
+}
+
+// ExplicitCondition matches explicit content ranges where every frame has at
+// least the likelihood, and which last longer than the minimum duration. The
+// type of explicit content is adult, spoof, medical, violent or racy, and
+// is adult when not set
+type ExplicitCondition struct {
+	Type        string   `yaml:"type"`
+	Likelihood  string   `yaml:"likelihood"`
+	MinDuration Duration `yaml:"min_duration"`
+	dimension   service.ExplicitDimension
+	likelihood  service.LikelihoodType
+}
+
+// Result is the verdict for annotations, and the evidence for the verdict
+type Result struct {
+	Verdict  Verdict
+	Evidence []*Evidence
+}
+
+// Evidence is a time range which matched a rule
+type Evidence struct {
+	Rule        string
+	Verdict     Verdict
+	Description string
+	Start       time.Duration
+	End         time.Duration
+	Confidence  float64
+	Likelihood  service.LikelihoodType
+}
+
+// Duration is a time.Duration which is read as a string such as "2s"
+type Duration time.Duration
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+// Verdict is the outcome of evaluating a policy, in order of severity
+type Verdict uint
+
+const (
+	VERDICT_ALLOW Verdict = iota
+	VERDICT_REVIEW
+	VERDICT_BLOCK
+)
+
+var (
+	ErrInvalidPolicy = errors.New("Invalid policy")
+)
+
+var (
+	explicitDescription = map[service.ExplicitDimension]string{
+		service.EXPLICIT_ADULT:   "explicit content",
+		service.EXPLICIT_SPOOF:   "spoof content",
+		service.EXPLICIT_MEDICAL: "medical content",
+		service.EXPLICIT_VIOLENT: "violent content",
+		service.EXPLICIT_RACY:    "racy content",
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Load returns a policy from a YAML or JSON file
+func Load(path string) (*Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if policy, err := Read(file); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	} else {
+		return policy, nil
+	}
+}
+
+// Read returns a policy from YAML or JSON, and checks each rule
+func Read(r io.Reader) (*Policy, error) {
+	policy := new(Policy)
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policy); err != nil && err != io.EOF {
+		return nil, err
+	}
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprint("rule ", i+1)
+		}
+		if (rule.Label == nil) == (rule.Explicit == nil) {
+			return nil, fmt.Errorf("%v: %v: Requires either a label or explicit condition", ErrInvalidPolicy, rule.Name)
+		}
+		if rule.Label != nil && rule.Label.Entity == "" {
+			return nil, fmt.Errorf("%v: %v: Missing entity", ErrInvalidPolicy, rule.Name)
+		}
+		if rule.Explicit != nil {
+			if likelihood, err := service.ParseLikelihood(rule.Explicit.Likelihood); err != nil || likelihood == service.LIKELIHOOD_UNSPECIFIED {
+				return nil, fmt.Errorf("%v: %v: Invalid likelihood %q", ErrInvalidPolicy, rule.Name, rule.Explicit.Likelihood)
+			} else {
+				rule.Explicit.likelihood = likelihood
+			}
+			if rule.Explicit.Type != "" {
+				if dimension, err := service.ParseExplicitDimension(rule.Explicit.Type); err != nil {
+					return nil, fmt.Errorf("%v: %v: Invalid type %q", ErrInvalidPolicy, rule.Name, rule.Explicit.Type)
+				} else {
+					rule.Explicit.dimension = dimension
+				}
+			}
+		}
+	}
+	return policy, nil
+}
+
+// AnnotationTypes returns the annotation types which the rules need, which
+// should be requested for the policy to be evaluated
+func (this *Policy) AnnotationTypes() service.AnnotationType {
+	flags := service.ANNOTATION_NONE
+	for _, rule := range this.Rules {
+		if rule.Label != nil {
+			flags |= service.ANNOTATION_LABEL
+		}
+		if rule.Explicit != nil {
+			flags |= service.ANNOTATION_EXPLICIT_CONTENT
+		}
+	}
+	return flags
+}
+
+// Evaluate returns the verdict for annotations, with the evidence for every
+// rule which matched in order of offset
+func (this *Policy) Evaluate(annotations *service.Annotations) (*Result, error) {
+	result := &Result{Verdict: VERDICT_ALLOW, Evidence: make([]*Evidence, 0)}
+	for _, rule := range this.Rules {
+		var evidence []*Evidence
+		var err error
+		if rule.Label != nil {
+			evidence = rule.Label.evaluate(rule, annotations)
+		} else if evidence, err = rule.Explicit.evaluate(rule, annotations); err != nil {
+			return nil, err
+		}
+		if len(evidence) > 0 && rule.Verdict > result.Verdict {
+			result.Verdict = rule.Verdict
+		}
+		result.Evidence = append(result.Evidence, evidence...)
+	}
+	sort.SliceStable(result.Evidence, func(i, j int) bool {
+		return result.Evidence[i].Start < result.Evidence[j].Start
+	})
+	return result, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (this *LabelCondition) evaluate(rule *Rule, annotations *service.Annotations) []*Evidence {
+	evidence := make([]*Evidence, 0)
+	for _, labels := range [][]*service.EntityAnnotation{annotations.ShotLabels, annotations.SegmentLabels, annotations.FrameLabels} {
+		for _, label := range labels {
+			if this.matches(label) == false {
+				continue
+			}
+			matched := make([]*service.Segment, 0, len(label.Segments))
+			for _, segment := range label.Segments {
+				if segment.Confidence >= this.MinConfidence {
+					matched = append(matched, segment)
+				}
+			}
+			// Frames have no duration, so are matched individually
+			ranges := segments.FromSegments(matched)
+			for _, segment := range matched {
+				if segment.StartOffset == segment.EndOffset {
+					ranges = append(ranges, &segments.Range{Start: segment.StartOffset, End: segment.EndOffset, Confidence: segment.Confidence})
+				}
+			}
+			for _, r := range ranges {
+				if this.MinDuration.exceeded(r.Duration()) {
+					evidence = append(evidence, &Evidence{
+						Rule:        rule.Name,
+						Verdict:     rule.Verdict,
+						Description: label.Entity.Description,
+						Start:       r.Start,
+						End:         r.End,
+						Confidence:  r.Confidence,
+					})
+				}
+			}
+		}
+	}
+	return evidence
+}
+
+func (this *LabelCondition) matches(label *service.EntityAnnotation) bool {
+	for _, entity := range append([]*service.Entity{label.Entity}, label.Categories...) {
+		if entity == nil {
+			continue
+		}
+		if entity.EntityId == this.Entity || strings.EqualFold(entity.Description, this.Entity) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *ExplicitCondition) evaluate(rule *Rule, annotations *service.Annotations) ([]*Evidence, error) {
+	summary, err := moderation.AnalyseExplicit(annotations, &moderation.ExplicitConfig{
+		Dimension: this.dimension,
+		Enter:     this.likelihood,
+		Exit:      this.likelihood,
+	})
+	if err != nil {
+		return nil, err
+	}
+	evidence := make([]*Evidence, 0, len(summary.Ranges))
+	for _, r := range summary.Ranges {
+		if this.MinDuration.exceeded(r.Duration()) {
+			evidence = append(evidence, &Evidence{
+				Rule:        rule.Name,
+				Verdict:     rule.Verdict,
+				Description: explicitDescription[this.dimension],
+				Start:       r.Start,
+				End:         r.End,
+				Likelihood:  r.Peak,
+			})
+		}
+	}
+	return evidence, nil
+}
+
+// UnmarshalText reads a verdict from allow, review or block
+func (this *Verdict) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "allow":
+		*this = VERDICT_ALLOW
+	case "review":
+		*this = VERDICT_REVIEW
+	case "block":
+		*this = VERDICT_BLOCK
+	default:
+		return fmt.Errorf("%v: Invalid verdict %q", ErrInvalidPolicy, string(text))
+	}
+	return nil
+}
+
+// exceeded returns true if a duration is longer than the minimum duration,
+// or when there is no minimum duration
+func (this Duration) exceeded(duration time.Duration) bool {
+	return this == 0 || duration > time.Duration(this)
+}
+
+// UnmarshalText reads a duration such as 2s or 1m30s
+func (this *Duration) UnmarshalText(text []byte) error {
+	if duration, err := time.ParseDuration(string(text)); err != nil {
+		return fmt.Errorf("%v: %v", ErrInvalidPolicy, err)
+	} else {
+		*this = Duration(duration)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (v Verdict) String() string {
+	switch v {
+	case VERDICT_ALLOW:
+		return "allow"
+	case VERDICT_REVIEW:
+		return "review"
+	case VERDICT_BLOCK:
+		return "block"
+	default:
+		return "[?? Invalid Verdict value]"
+	}
+}
+
+func (e *Evidence) String() string {
+	return fmt.Sprintf("<Evidence rule=%q verdict=%v description=%q start=%v end=%v>", e.Rule, e.Verdict, e.Description, e.Start, e.End)
+}
@@ -0,0 +1,194 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestLoad(t *testing.T) {
+	policy, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Rules) != 2 {
+		t.Fatalf("Unexpected rules %v", policy.Rules)
+	}
+	if rule := policy.Rules[0]; rule.Name != "explicit" || rule.Verdict != VERDICT_BLOCK || rule.Explicit == nil || rule.Label != nil {
+		t.Errorf("Unexpected rule %v", rule.Name)
+	} else if rule.Explicit.likelihood != service.LIKELIHOOD_LIKELY || rule.Explicit.dimension != service.EXPLICIT_ADULT || rule.Explicit.MinDuration != Duration(2*time.Second) {
+		t.Errorf("Unexpected explicit condition %+v", rule.Explicit)
+	}
+	if rule := policy.Rules[1]; rule.Name != "weapon" || rule.Verdict != VERDICT_REVIEW || rule.Label == nil || rule.Label.Entity != "Weapon" || rule.Label.MinConfidence != 0.7 {
+		t.Errorf("Unexpected rule %v", rule.Name)
+	}
+	if flags := policy.AnnotationTypes(); flags != service.ANNOTATION_LABEL|service.ANNOTATION_EXPLICIT_CONTENT {
+		t.Errorf("Unexpected annotation types %v", flags)
+	}
+	if _, err := Load("testdata/missing.yaml"); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"json", `{"rules":[{"verdict":"review","label":{"entity":"/m/gun","min_duration":"1s"}}]}`},
+		{"violent", "rules: [{verdict: BLOCK, explicit: {type: violent, likelihood: likelihood_very_likely}}]"},
+		{"empty", ""},
+		{"no rules", "rules: []"},
+	}
+	for _, test := range tests {
+		if _, err := Read(strings.NewReader(test.policy)); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{"verdict", "rules: [{verdict: nope, label: {entity: x}}]"},
+		{"no condition", "rules: [{verdict: block}]"},
+		{"two conditions", "rules: [{verdict: block, label: {entity: x}, explicit: {likelihood: likely}}]"},
+		{"entity", "rules: [{verdict: block, label: {min_confidence: 0.5}}]"},
+		{"likelihood", "rules: [{verdict: block, explicit: {likelihood: meh}}]"},
+		{"unknown likelihood", "rules: [{verdict: block, explicit: {likelihood: unknown}}]"},
+		{"missing likelihood", "rules: [{verdict: block, explicit: {type: racy}}]"},
+		{"type", "rules: [{verdict: block, explicit: {type: gory, likelihood: likely}}]"},
+		{"duration", "rules: [{verdict: block, label: {entity: x, min_duration: soon}}]"},
+		{"unknown field", "rules: [{verdict: block, labels: {entity: x}}]"},
+	}
+	for _, test := range tests {
+		if _, err := Read(strings.NewReader(test.policy)); err == nil {
+			t.Errorf("%v: Expected error", test.name)
+		} else if test.name != "unknown field" && strings.Contains(err.Error(), ErrInvalidPolicy.Error()) == false {
+			t.Errorf("%v: Unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	annotations := sampleAnnotations()
+	policy, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := policy.Evaluate(annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != VERDICT_BLOCK {
+		t.Errorf("Unexpected verdict %v", result.Verdict)
+	}
+	expected := []*Evidence{
+		{Rule: "explicit", Verdict: VERDICT_BLOCK, Description: "explicit content", Start: 0, End: 5 * time.Second, Likelihood: service.LIKELIHOOD_VERY_LIKELY},
+		{Rule: "weapon", Verdict: VERDICT_REVIEW, Description: "gun", Start: 0, End: 4 * time.Second, Confidence: 0.9},
+		{Rule: "weapon", Verdict: VERDICT_REVIEW, Description: "weapon", Start: 5 * time.Second, End: 5 * time.Second, Confidence: 0.75},
+	}
+	if len(result.Evidence) != len(expected) {
+		t.Fatalf("Unexpected evidence %v", result.Evidence)
+	}
+	for i, evidence := range result.Evidence {
+		if *evidence != *expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], evidence)
+		}
+	}
+}
+
+func TestEvaluateConditions(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		verdict  Verdict
+		evidence int
+	}{
+		{"entity id", `rules: [{verdict: review, label: {entity: /m/gun, min_duration: 1s}}]`, VERDICT_REVIEW, 1},
+		{"description", `rules: [{verdict: review, label: {entity: GUN}}]`, VERDICT_REVIEW, 1},
+		{"min duration", `rules: [{verdict: review, label: {entity: gun, min_duration: 4s}}]`, VERDICT_ALLOW, 0},
+		{"frame min duration", `rules: [{verdict: review, label: {entity: weapon, min_duration: 5s}}]`, VERDICT_ALLOW, 0},
+		{"min confidence", `rules: [{verdict: review, label: {entity: weapon, min_confidence: 0.85}}]`, VERDICT_REVIEW, 1},
+		{"no match", `rules: [{verdict: block, label: {entity: cat}}]`, VERDICT_ALLOW, 0},
+		{"explicit duration", `rules: [{verdict: block, explicit: {likelihood: likely, min_duration: 5s}}]`, VERDICT_ALLOW, 0},
+		{"explicit type", `rules: [{verdict: block, explicit: {type: violent, likelihood: possible}}]`, VERDICT_ALLOW, 0},
+		{"most severe", `rules: [{verdict: block, label: {entity: cat}}, {verdict: review, label: {entity: gun}}, {verdict: allow, label: {entity: weapon}}]`, VERDICT_REVIEW, 3},
+	}
+	annotations := sampleAnnotations()
+	for _, test := range tests {
+		policy, err := Read(strings.NewReader(test.policy))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if result, err := policy.Evaluate(annotations); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if result.Verdict != test.verdict || len(result.Evidence) != test.evidence {
+			t.Errorf("%v: Unexpected verdict %v with evidence %v", test.name, result.Verdict, result.Evidence)
+		}
+	}
+}
+
+func TestEvaluateEmpty(t *testing.T) {
+	policy, err := Load("testdata/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := policy.Evaluate(&service.Annotations{}); err != nil {
+		t.Error(err)
+	} else if result.Verdict != VERDICT_ALLOW || len(result.Evidence) != 0 {
+		t.Errorf("Unexpected result %v %v", result.Verdict, result.Evidence)
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	var verdict Verdict
+	for _, value := range []string{"allow", "Review", "BLOCK"} {
+		if err := verdict.UnmarshalText([]byte(value)); err != nil {
+			t.Error(err)
+		} else if verdict.String() != strings.ToLower(value) {
+			t.Errorf("Expected %v, got %v", value, verdict)
+		}
+	}
+	if err := verdict.UnmarshalText([]byte("deny")); err == nil {
+		t.Error("Expected error for invalid verdict")
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// sampleAnnotations returns a gun segment label in the weapon category for
+// four seconds, a weapon frame label at five seconds, and very likely adult
+// content frames for five seconds
+func sampleAnnotations() *service.Annotations {
+	annotations := &service.Annotations{
+		SegmentLabels: []*service.EntityAnnotation{{
+			Entity:     &service.Entity{EntityId: "/m/gun", Description: "gun"},
+			Categories: []*service.Entity{{Description: "weapon"}},
+			Segments: []*service.Segment{
+				{StartOffset: 0, EndOffset: 3 * time.Second, Confidence: 0.8},
+				{StartOffset: 3 * time.Second, EndOffset: 4 * time.Second, Confidence: 0.9},
+			},
+		}},
+		FrameLabels: []*service.EntityAnnotation{{
+			Entity:   &service.Entity{Description: "weapon"},
+			Segments: []*service.Segment{{StartOffset: 5 * time.Second, EndOffset: 5 * time.Second, Confidence: 0.75}},
+		}},
+	}
+	for i := 0; i < 5; i++ {
+		annotations.ExplicitContent = append(annotations.ExplicitContent, &service.ExplicitContentAnnotation{
+			Offset:     time.Duration(i) * time.Second,
+			Likelihood: service.LIKELIHOOD_VERY_LIKELY,
+		})
+	}
+	return annotations
+}
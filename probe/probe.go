@@ -0,0 +1,421 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Info defines the metadata for an MP4 or QuickTime container
+type Info struct {
+	Brand      string
+	Compatible []string
+	Duration   time.Duration
+	Timescale  uint32
+	Tracks     []*Track
+}
+
+// Track defines the metadata for a single track within the container
+type Track struct {
+	Id          uint32
+	Handler     string
+	Codec       string
+	Duration    time.Duration
+	Timescale   uint32
+	Width       uint32
+	Height      uint32
+	SampleCount uint64
+	FrameRate   float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type box struct {
+	kind   string
+	offset int64
+	size   int64
+	header int64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Maximum size of a leaf box which is read into memory
+	box_MAX_PAYLOAD = 64 * 1024 * 1024
+	// Maximum depth of nested boxes
+	box_MAX_DEPTH = 16
+)
+
+var (
+	ErrNotContainer = errors.New("Not an MP4 or QuickTime container")
+	ErrMissingMovie = errors.New("Missing movie header")
+	ErrInvalidBox   = errors.New("Invalid box")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Open returns the metadata for a local file
+func Open(filename string) (*Info, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(file, stat.Size())
+}
+
+// Read returns the metadata for a container of a certain size. Reading
+// stops at the first invalid top-level box, such as trailing padding, so
+// the container is only rejected when there is no movie header before it
+func Read(r io.ReaderAt, size int64) (*Info, error) {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil && err != ErrInvalidBox {
+		return nil, err
+	} else if len(boxes) == 0 {
+		return nil, ErrNotContainer
+	}
+	info := &Info{
+		Compatible: make([]string, 0),
+		Tracks:     make([]*Track, 0),
+	}
+	movie := false
+	for _, b := range boxes {
+		switch b.kind {
+		case "ftyp":
+			if err := info.readFileType(r, b); err != nil {
+				return nil, err
+			}
+		case "moov":
+			if err := info.readMovie(r, b, 1); err != nil {
+				return nil, err
+			}
+			movie = true
+		}
+	}
+	if movie == false {
+		if info.Brand == "" {
+			return nil, ErrNotContainer
+		}
+		return nil, ErrMissingMovie
+	}
+	return info, nil
+}
+
+// Video returns the first video track, or nil
+func (this *Info) Video() *Track {
+	for _, track := range this.Tracks {
+		if track.Handler == "vide" {
+			return track
+		}
+	}
+	return nil
+}
+
+// Audio returns the first audio track, or nil
+func (this *Info) Audio() *Track {
+	for _, track := range this.Tracks {
+		if track.Handler == "soun" {
+			return track
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// readBoxes returns the boxes between two offsets. When a box is invalid,
+// the boxes before it are returned with ErrInvalidBox
+func readBoxes(r io.ReaderAt, offset, end int64) ([]*box, error) {
+	boxes := make([]*box, 0)
+	header := make([]byte, 16)
+	for offset+8 <= end {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+		b := &box{
+			kind:   string(header[4:8]),
+			offset: offset,
+			size:   int64(binary.BigEndian.Uint32(header[0:4])),
+			header: 8,
+		}
+		switch b.size {
+		case 0:
+			// Box extends to the end
+			b.size = end - offset
+		case 1:
+			// Box has a 64-bit size
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+			b.size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.header = 16
+		}
+		if b.size < b.header || offset+b.size > end || isPrintable(b.kind) == false {
+			return boxes, ErrInvalidBox
+		}
+		boxes = append(boxes, b)
+		offset += b.size
+	}
+	return boxes, nil
+}
+
+// readChildren returns the boxes within a box
+func readChildren(r io.ReaderAt, parent *box, skip int64, depth int) ([]*box, error) {
+	if depth > box_MAX_DEPTH {
+		return nil, ErrInvalidBox
+	}
+	return readBoxes(r, parent.offset+parent.header+skip, parent.offset+parent.size)
+}
+
+// readPayload returns the contents of a box
+func readPayload(r io.ReaderAt, b *box) ([]byte, error) {
+	size := b.size - b.header
+	if size > box_MAX_PAYLOAD {
+		return nil, ErrInvalidBox
+	}
+	payload := make([]byte, size)
+	if _, err := r.ReadAt(payload, b.offset+b.header); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (this *Info) readFileType(r io.ReaderAt, b *box) error {
+	payload, err := readPayload(r, b)
+	if err != nil {
+		return err
+	}
+	if len(payload) < 8 {
+		return ErrInvalidBox
+	}
+	this.Brand = string(payload[0:4])
+	for i := 8; i+4 <= len(payload); i += 4 {
+		this.Compatible = append(this.Compatible, string(payload[i:i+4]))
+	}
+	return nil
+}
+
+func (this *Info) readMovie(r io.ReaderAt, moov *box, depth int) error {
+	children, err := readChildren(r, moov, 0, depth)
+	if err != nil {
+		return err
+	}
+	for _, b := range children {
+		switch b.kind {
+		case "mvhd":
+			payload, err := readPayload(r, b)
+			if err != nil {
+				return err
+			}
+			if this.Timescale, this.Duration, err = readHeader(payload); err != nil {
+				return err
+			}
+		case "trak":
+			track := new(Track)
+			if err := track.read(r, b, depth+1); err != nil {
+				return err
+			}
+			this.Tracks = append(this.Tracks, track)
+		}
+	}
+	if this.Timescale == 0 {
+		return ErrMissingMovie
+	}
+	return nil
+}
+
+func (this *Track) read(r io.ReaderAt, parent *box, depth int) error {
+	children, err := readChildren(r, parent, 0, depth)
+	if err != nil {
+		return err
+	}
+	for _, b := range children {
+		switch b.kind {
+		case "tkhd":
+			payload, err := readPayload(r, b)
+			if err != nil {
+				return err
+			}
+			if err := this.readTrackHeader(payload); err != nil {
+				return err
+			}
+		case "mdia", "minf", "stbl":
+			if err := this.read(r, b, depth+1); err != nil {
+				return err
+			}
+		case "mdhd":
+			payload, err := readPayload(r, b)
+			if err != nil {
+				return err
+			}
+			if this.Timescale, this.Duration, err = readHeader(payload); err != nil {
+				return err
+			}
+		case "hdlr":
+			payload, err := readPayload(r, b)
+			if err != nil {
+				return err
+			}
+			if len(payload) < 12 {
+				return ErrInvalidBox
+			}
+			this.Handler = string(payload[8:12])
+		case "stsd":
+			if err := this.readSampleDescription(r, b, depth+1); err != nil {
+				return err
+			}
+		case "stts":
+			payload, err := readPayload(r, b)
+			if err != nil {
+				return err
+			}
+			if err := this.readTimeToSample(payload); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readHeader returns the timescale and duration from a movie or media header
+func readHeader(payload []byte) (uint32, time.Duration, error) {
+	var timescale uint32
+	var duration uint64
+	if len(payload) < 4 {
+		return 0, 0, ErrInvalidBox
+	}
+	switch payload[0] {
+	case 0:
+		if len(payload) < 20 {
+			return 0, 0, ErrInvalidBox
+		}
+		timescale = binary.BigEndian.Uint32(payload[12:16])
+		duration = uint64(binary.BigEndian.Uint32(payload[16:20]))
+		if duration == 0xFFFFFFFF {
+			duration = 0
+		}
+	case 1:
+		if len(payload) < 32 {
+			return 0, 0, ErrInvalidBox
+		}
+		timescale = binary.BigEndian.Uint32(payload[20:24])
+		duration = binary.BigEndian.Uint64(payload[24:32])
+		if duration == 0xFFFFFFFFFFFFFFFF {
+			duration = 0
+		}
+	default:
+		return 0, 0, ErrInvalidBox
+	}
+	if timescale == 0 {
+		return 0, 0, ErrInvalidBox
+	}
+	return timescale, scale(duration, timescale), nil
+}
+
+func (this *Track) readTrackHeader(payload []byte) error {
+	// Track ID follows the creation and modification times, and the
+	// width and height are 16.16 fixed point at the end
+	switch {
+	case len(payload) >= 84 && payload[0] == 0:
+		this.Id = binary.BigEndian.Uint32(payload[12:16])
+		this.Width = binary.BigEndian.Uint32(payload[76:80]) >> 16
+		this.Height = binary.BigEndian.Uint32(payload[80:84]) >> 16
+	case len(payload) >= 96 && payload[0] == 1:
+		this.Id = binary.BigEndian.Uint32(payload[20:24])
+		this.Width = binary.BigEndian.Uint32(payload[88:92]) >> 16
+		this.Height = binary.BigEndian.Uint32(payload[92:96]) >> 16
+	default:
+		return ErrInvalidBox
+	}
+	return nil
+}
+
+func (this *Track) readSampleDescription(r io.ReaderAt, stsd *box, depth int) error {
+	// Skip version, flags and entry count
+	entries, err := readChildren(r, stsd, 8, depth)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	entry := entries[0]
+	this.Codec = entry.kind
+	if this.Handler == "vide" && entry.size-entry.header >= 28 {
+		// Visual sample entry has the width and height after 24 bytes
+		dimensions := make([]byte, 4)
+		if _, err := r.ReadAt(dimensions, entry.offset+entry.header+24); err != nil {
+			return err
+		}
+		this.Width = uint32(binary.BigEndian.Uint16(dimensions[0:2]))
+		this.Height = uint32(binary.BigEndian.Uint16(dimensions[2:4]))
+	}
+	return nil
+}
+
+func (this *Track) readTimeToSample(payload []byte) error {
+	if len(payload) < 8 {
+		return ErrInvalidBox
+	}
+	count := binary.BigEndian.Uint32(payload[4:8])
+	if uint64(len(payload)) < 8+uint64(count)*8 {
+		return ErrInvalidBox
+	}
+	var samples, total uint64
+	for i := uint32(0); i < count; i++ {
+		entry := payload[8+i*8:]
+		samples += uint64(binary.BigEndian.Uint32(entry[0:4]))
+		total += uint64(binary.BigEndian.Uint32(entry[0:4])) * uint64(binary.BigEndian.Uint32(entry[4:8]))
+	}
+	this.SampleCount = samples
+	if total > 0 && this.Timescale > 0 {
+		this.FrameRate = float64(samples) * float64(this.Timescale) / float64(total)
+	}
+	return nil
+}
+
+// scale returns a duration in units of a timescale
+func scale(value uint64, timescale uint32) time.Duration {
+	seconds := value / uint64(timescale)
+	remainder := value % uint64(timescale)
+	return time.Duration(seconds)*time.Second + time.Duration(remainder*uint64(time.Second)/uint64(timescale))
+}
+
+// isPrintable returns true if a box type is four printable characters
+func isPrintable(kind string) bool {
+	for i := 0; i < len(kind); i++ {
+		if kind[i] < 0x20 || kind[i] > 0x7E {
+			// Some QuickTime boxes start with the copyright symbol
+			if i == 0 && kind[i] == 0xA9 {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (this *Info) String() string {
+	return fmt.Sprintf("Info{ brand=%v duration=%v timescale=%v tracks=%v }", this.Brand, this.Duration, this.Timescale, this.Tracks)
+}
+
+func (this *Track) String() string {
+	return fmt.Sprintf("Track{ id=%v handler=%v codec=%v duration=%v size=%vx%v fps=%.3f }", this.Id, this.Handler, this.Codec, this.Duration, this.Width, this.Height, this.FrameRate)
+}
@@ -0,0 +1,181 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestRead(t *testing.T) {
+	for _, version := range []uint32{0, 1} {
+		file := sampleFile(version, "vide")
+		info, err := Read(bytes.NewReader(file), int64(len(file)))
+		if err != nil {
+			t.Fatalf("Version %v: %v", version, err)
+		}
+		if info.Brand != "isom" || len(info.Compatible) != 2 || info.Compatible[1] != "avc1" {
+			t.Errorf("Version %v: Unexpected brand %v %v", version, info.Brand, info.Compatible)
+		}
+		if info.Timescale != 1000 || info.Duration != 38752*time.Millisecond {
+			t.Errorf("Version %v: Unexpected movie header %v", version, info)
+		}
+		if len(info.Tracks) != 1 {
+			t.Fatalf("Version %v: Unexpected tracks %v", version, info.Tracks)
+		}
+		track := info.Video()
+		if track == nil {
+			t.Fatalf("Version %v: Missing video track", version)
+		} else if info.Audio() != nil {
+			t.Errorf("Version %v: Unexpected audio track", version)
+		}
+		if track.Id != 1 || track.Handler != "vide" || track.Codec != "avc1" {
+			t.Errorf("Version %v: Unexpected track %v", version, track)
+		}
+		if track.Timescale != 30000 || track.Duration != 38752*time.Millisecond {
+			t.Errorf("Version %v: Unexpected media header %v", version, track)
+		}
+		if track.Width != 1280 || track.Height != 720 {
+			t.Errorf("Version %v: Unexpected size %vx%v", version, track.Width, track.Height)
+		}
+		if track.SampleCount != 1161 || math.Abs(track.FrameRate-29.97) > 0.001 {
+			t.Errorf("Version %v: Unexpected samples %v at %v fps", version, track.SampleCount, track.FrameRate)
+		}
+	}
+}
+
+func TestReadAudio(t *testing.T) {
+	file := sampleFile(0, "soun")
+	info, err := Read(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track := info.Audio(); track == nil {
+		t.Error("Missing audio track")
+	} else if track.Codec != "avc1" || track.Width != 1280 || track.Height != 720 {
+		// Dimensions come from the track header, not the sample entry
+		t.Errorf("Unexpected track %v", track)
+	}
+	if info.Video() != nil {
+		t.Error("Unexpected video track")
+	}
+}
+
+func TestReadPadding(t *testing.T) {
+	tests := []struct {
+		name    string
+		trailer []byte
+	}{
+		{"zeros", make([]byte, 8)},
+		{"long zeros", make([]byte, 4096)},
+		{"short zeros", make([]byte, 4)},
+		{"truncated box", mkbox("free", make([]byte, 16))[:12]},
+		{"garbage", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x02, 0x03, 0x04}},
+	}
+	for _, test := range tests {
+		file := append(sampleFile(0, "vide"), test.trailer...)
+		info, err := Read(bytes.NewReader(file), int64(len(file)))
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+		} else if info.Duration != 38752*time.Millisecond || len(info.Tracks) != 1 {
+			t.Errorf("%v: Unexpected info %v", test.name, info)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	ftyp := mkbox("ftyp", []byte("isom"), u32(512), []byte("isom"))
+	hdlr := mkbox("hdlr", u32(0, 0))
+	stts := mkbox("stts", u32(0, 2, 1161, 1001))
+	tests := []struct {
+		name string
+		file []byte
+		err  error
+	}{
+		{"empty", []byte{}, ErrNotContainer},
+		{"riff", []byte("RIFF....AVI LIST"), ErrNotContainer},
+		{"zeros", make([]byte, 64), ErrNotContainer},
+		{"ftyp only", ftyp, ErrMissingMovie},
+		{"ftyp with padding", append(ftyp, make([]byte, 8)...), ErrMissingMovie},
+		{"moov after padding", append(append(ftyp, make([]byte, 8)...), mkbox("moov")...), ErrMissingMovie},
+		{"mdat only", mkbox("mdat", make([]byte, 16)), ErrNotContainer},
+		{"moov without mvhd", append(ftyp, mkbox("moov")...), ErrMissingMovie},
+		{"short hdlr", append(ftyp, mkbox("moov", mvhd(0), mkbox("trak", hdlr))...), ErrInvalidBox},
+		{"short stts", append(ftyp, mkbox("moov", mvhd(0), mkbox("trak", stts))...), ErrInvalidBox},
+		{"bad mvhd version", append(ftyp, mkbox("moov", mkbox("mvhd", u32(2<<24, 0, 0, 1000, 1000)))...), ErrInvalidBox},
+	}
+	for _, test := range tests {
+		if _, err := Read(bytes.NewReader(test.file), int64(len(test.file))); err != test.err {
+			t.Errorf("%v: Expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		value     uint64
+		timescale uint32
+		duration  time.Duration
+	}{
+		{0, 1000, 0},
+		{38752, 1000, 38752 * time.Millisecond},
+		{1001, 30000, 33366666 * time.Nanosecond},
+		{1 << 40, 90000, 12216795*time.Second + 864177777},
+	}
+	for _, test := range tests {
+		if duration := scale(test.value, test.timescale); duration != test.duration {
+			t.Errorf("%v/%v: Expected %v, got %v", test.value, test.timescale, test.duration, duration)
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// sampleFile returns a container with a single 1280x720 track at 29.97fps
+// lasting 38.752 seconds, with version 0 or 1 headers
+func sampleFile(version uint32, handler string) []byte {
+	tkhd := mkbox("tkhd", u32(version<<24), u32v(version, 0), u32v(version, 0), u32(1, 0), u32v(version, 38752), make([]byte, 52), u32(1280<<16, 720<<16))
+	mdhd := mkbox("mdhd", u32(version<<24), u32v(version, 0), u32v(version, 0), u32(30000), u32v(version, 1162560), u32(0))
+	hdlr := mkbox("hdlr", u32(0, 0), []byte(handler), make([]byte, 12))
+	avc1 := mkbox("avc1", make([]byte, 24), []byte{0x05, 0x00, 0x02, 0xd0}, make([]byte, 50))
+	stsd := mkbox("stsd", u32(0, 1), avc1)
+	stts := mkbox("stts", u32(0, 1, 1161, 1001))
+	stbl := mkbox("stbl", stsd, stts)
+	mdia := mkbox("mdia", mdhd, hdlr, mkbox("minf", stbl))
+	moov := mkbox("moov", mvhd(version), mkbox("trak", tkhd, mdia))
+	ftyp := mkbox("ftyp", []byte("isom"), u32(512), []byte("isomavc1"))
+	return bytes.Join([][]byte{ftyp, mkbox("mdat", make([]byte, 100)), moov}, nil)
+}
+
+// mvhd returns a movie header with a timescale of 1000
+func mvhd(version uint32) []byte {
+	return mkbox("mvhd", u32(version<<24), u32v(version, 0), u32v(version, 0), u32(1000), u32v(version, 38752), make([]byte, 80))
+}
+
+// mkbox returns a box with a payload
+func mkbox(kind string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return bytes.Join([][]byte{u32(uint32(8 + len(body))), []byte(kind), body}, nil)
+}
+
+// u32 returns big-endian 32-bit values
+func u32(values ...uint32) []byte {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(data[i*4:], value)
+	}
+	return data
+}
+
+// u32v returns a 32-bit value for version 0 or a 64-bit value for version 1
+func u32v(version, value uint32) []byte {
+	if version == 1 {
+		return u32(0, value)
+	}
+	return u32(value)
+}
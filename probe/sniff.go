@@ -0,0 +1,148 @@
+package probe
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Format is the container type of a video file
+type Format uint
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	FORMAT_UNKNOWN Format = iota
+	FORMAT_MP4
+	FORMAT_QUICKTIME
+	FORMAT_AVI
+	FORMAT_MATROSKA
+	FORMAT_WEBM
+	FORMAT_MPEGTS
+	FORMAT_FLV
+)
+
+const (
+	// Number of bytes read to determine the format
+	sniff_SIZE = 1024
+	// MPEG transport stream packet sizes, without and with timestamp prefix
+	mpegts_PACKET_SIZE   = 188
+	mpegts_M2TS_SIZE     = 192
+	mpegts_SYNC_BYTE     = 0x47
+	mpegts_SYNC_PACKETS  = 3
+	matroska_HEADER_SIZE = 64
+)
+
+var (
+	// Top-level boxes which can start a QuickTime file without a file type box
+	quicktime_boxes = []string{"moov", "mdat", "free", "skip", "wide", "pnot"}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// SniffFile returns the container format of a local file from the magic bytes
+// at the start of the file
+func SniffFile(filename string) (Format, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return FORMAT_UNKNOWN, err
+	}
+	defer file.Close()
+	return Sniff(file)
+}
+
+// Sniff returns the container format from the magic bytes at the start of
+// the data
+func Sniff(r io.ReaderAt) (Format, error) {
+	header := make([]byte, sniff_SIZE)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return FORMAT_UNKNOWN, err
+	}
+	header = header[:n]
+	switch {
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		if string(header[8:12]) == "qt  " {
+			return FORMAT_QUICKTIME, nil
+		}
+		return FORMAT_MP4, nil
+	case len(header) >= 8 && isQuickTimeBox(string(header[4:8])):
+		return FORMAT_QUICKTIME, nil
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return FORMAT_AVI, nil
+	case len(header) >= 4 && bytes.Equal(header[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// The EBML header contains the document type
+		if bytes.Contains(header[:minInt(len(header), matroska_HEADER_SIZE)], []byte("webm")) {
+			return FORMAT_WEBM, nil
+		}
+		return FORMAT_MATROSKA, nil
+	case len(header) >= 4 && string(header[0:3]) == "FLV" && header[3] == 0x01:
+		return FORMAT_FLV, nil
+	case isTransportStream(header, 0, mpegts_PACKET_SIZE) || isTransportStream(header, 4, mpegts_M2TS_SIZE):
+		return FORMAT_MPEGTS, nil
+	default:
+		return FORMAT_UNKNOWN, nil
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func isQuickTimeBox(kind string) bool {
+	for _, box := range quicktime_boxes {
+		if kind == box {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransportStream returns true if several consecutive packets start with
+// the sync byte
+func isTransportStream(header []byte, offset, size int) bool {
+	if len(header) < offset+size*(mpegts_SYNC_PACKETS-1)+1 {
+		return false
+	}
+	for i := 0; i < mpegts_SYNC_PACKETS; i++ {
+		if header[offset+i*size] != mpegts_SYNC_BYTE {
+			return false
+		}
+	}
+	return true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (f Format) String() string {
+	switch f {
+	case FORMAT_MP4:
+		return "FORMAT_MP4"
+	case FORMAT_QUICKTIME:
+		return "FORMAT_QUICKTIME"
+	case FORMAT_AVI:
+		return "FORMAT_AVI"
+	case FORMAT_MATROSKA:
+		return "FORMAT_MATROSKA"
+	case FORMAT_WEBM:
+		return "FORMAT_WEBM"
+	case FORMAT_MPEGTS:
+		return "FORMAT_MPEGTS"
+	case FORMAT_FLV:
+		return "FORMAT_FLV"
+	default:
+		return "FORMAT_UNKNOWN"
+	}
+}
@@ -0,0 +1,543 @@
+package rekognition
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Client annotates videos in Amazon S3 with the Rekognition Video API. Labels
+// are returned as frame labels, segment detection as shots and content
+// moderation as explicit content
+type Client struct {
+	client      *http.Client
+	region      string
+	endpoint    string
+	credentials *Credentials
+	status      map[string]*job
+}
+
+// Credentials are the AWS access keys used to sign requests
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Option sets a parameter for a new client
+type Option func(*Client) error
+
+// Error is an error returned by the API
+type Error struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+// StartError is returned when a job couldn't be started after jobs for
+// other annotation types had started. Rekognition jobs cannot be cancelled,
+// so the started jobs, keyed by annotation type, continue to run
+type StartError struct {
+	Err     error
+	Started map[service.AnnotationType]string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// job tracks the Rekognition jobs for an operation, one for each type
+type job struct {
+	status *service.Status
+	jobs   map[service.AnnotationType]string
+}
+
+type startRequest struct {
+	Video struct {
+		S3Object s3Object `json:"S3Object"`
+	} `json:"Video"`
+	ClientRequestToken string   `json:"ClientRequestToken,omitempty"`
+	SegmentTypes       []string `json:"SegmentTypes,omitempty"`
+}
+
+type s3Object struct {
+	Bucket  string `json:"Bucket"`
+	Name    string `json:"Name"`
+	Version string `json:"Version,omitempty"`
+}
+
+type startResponse struct {
+	JobId string `json:"JobId"`
+}
+
+type getRequest struct {
+	JobId      string `json:"JobId"`
+	MaxResults int    `json:"MaxResults"`
+	NextToken  string `json:"NextToken,omitempty"`
+	SortBy     string `json:"SortBy,omitempty"`
+}
+
+type getResponse struct {
+	JobStatus        string            `json:"JobStatus"`
+	StatusMessage    string            `json:"StatusMessage"`
+	NextToken        string            `json:"NextToken"`
+	Labels           []*labelDetection `json:"Labels"`
+	ModerationLabels []*moderation     `json:"ModerationLabels"`
+	Segments         []*segment        `json:"Segments"`
+}
+
+type labelDetection struct {
+	Timestamp int64 `json:"Timestamp"`
+	Label     struct {
+		Name       string  `json:"Name"`
+		Confidence float64 `json:"Confidence"`
+		Parents    []struct {
+			Name string `json:"Name"`
+		} `json:"Parents"`
+	} `json:"Label"`
+}
+
+type moderation struct {
+	Timestamp       int64 `json:"Timestamp"`
+	ModerationLabel struct {
+		Name       string  `json:"Name"`
+		ParentName string  `json:"ParentName"`
+		Confidence float64 `json:"Confidence"`
+	} `json:"ModerationLabel"`
+}
+
+type segment struct {
+	Type                 string `json:"Type"`
+	StartTimestampMillis int64  `json:"StartTimestampMillis"`
+	EndTimestampMillis   int64  `json:"EndTimestampMillis"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	rekognition_SERVICE      = "rekognition"
+	rekognition_ENDPOINT     = "https://rekognition.%v.amazonaws.com/"
+	rekognition_TARGET       = "RekognitionService."
+	rekognition_CONTENT_TYPE = "application/x-amz-json-1.1"
+	rekognition_MAX_RESULTS  = 1000
+	rekognition_OPERATION    = "rekognition/"
+	s3_SCHEME                = "s3://"
+)
+
+var (
+	// Operations which start and get the results of each job
+	rekognition_operations = map[service.AnnotationType][2]string{
+		service.ANNOTATION_LABEL:            {"StartLabelDetection", "GetLabelDetection"},
+		service.ANNOTATION_SHOT_CHANGE:      {"StartSegmentDetection", "GetSegmentDetection"},
+		service.ANNOTATION_EXPLICIT_CONTENT: {"StartContentModeration", "GetContentModeration"},
+	}
+	// Top-level moderation categories for each type of explicit content
+	rekognition_moderation_map = map[string]service.ExplicitDimension{
+		"Explicit Nudity":     service.EXPLICIT_ADULT,
+		"Explicit":            service.EXPLICIT_ADULT,
+		"Suggestive":          service.EXPLICIT_RACY,
+		"Violence":            service.EXPLICIT_VIOLENT,
+		"Visually Disturbing": service.EXPLICIT_VIOLENT,
+	}
+)
+
+var (
+	ErrMissingCredentials = errors.New("Missing AWS credentials or region")
+	ErrInvalidUri         = errors.New("Invalid URI, expected s3://bucket/object")
+	ErrNotSupported       = errors.New("Not supported by Rekognition")
+	ErrJobFailed          = errors.New("Job failed")
+)
+
+var (
+	_ service.Annotator = (*Client)(nil)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// New returns a client for a region, such as us-east-1
+func New(region string, credentials *Credentials, options ...Option) (*Client, error) {
+	if region == "" || credentials == nil || credentials.AccessKeyId == "" || credentials.SecretAccessKey == "" {
+		return nil, ErrMissingCredentials
+	}
+	this := &Client{
+		client:      http.DefaultClient,
+		region:      region,
+		endpoint:    fmt.Sprintf(rekognition_ENDPOINT, region),
+		credentials: credentials,
+		status:      make(map[string]*job),
+	}
+	for _, option := range options {
+		if err := option(this); err != nil {
+			return nil, err
+		}
+	}
+	return this, nil
+}
+
+// NewFromEnvironment returns a client with the region and credentials from
+// the AWS_REGION (or AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+func NewFromEnvironment(options ...Option) (*Client, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return New(region, &Credentials{
+		AccessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}, options...)
+}
+
+// WithEndpoint sets the API endpoint, for example for a VPC endpoint
+func WithEndpoint(endpoint string) Option {
+	return func(this *Client) error {
+		if strings.HasSuffix(endpoint, "/") == false {
+			endpoint += "/"
+		}
+		this.endpoint = endpoint
+		return nil
+	}
+}
+
+// WithClient sets the HTTP client used for requests
+func WithClient(client *http.Client) Option {
+	return func(this *Client) error {
+		this.client = client
+		return nil
+	}
+}
+
+// Annotate starts a Rekognition job for each annotation type, and returns
+// the operation name. The uri is an Amazon S3 URI
+func (this *Client) Annotate(uri string, flags service.AnnotationType) (string, error) {
+	return this.AnnotateWithConfig(uri, flags, nil)
+}
+
+// AnnotateWithConfig starts a Rekognition job for each annotation type. The
+// detection parameters are ignored, except that segments are not supported
+func (this *Client) AnnotateWithConfig(uri string, flags service.AnnotationType, config *service.Config) (string, error) {
+	object, err := parseUri(uri)
+	if err != nil {
+		return "", err
+	}
+	if config != nil && len(config.Segments) > 0 {
+		return "", fmt.Errorf("Segments: %v", ErrNotSupported)
+	}
+	for _, feature := range service.Features() {
+		if _, exists := rekognition_operations[feature.Type]; flags&feature.Type != 0 && exists == false {
+			return "", fmt.Errorf("%v: %v", feature.Type, ErrNotSupported)
+		}
+	}
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	operation := &job{
+		status: &service.Status{
+			Name:        rekognition_OPERATION + token,
+			Uri:         uri,
+			Progress:    make(map[service.AnnotationType]*service.Progress, 3),
+			Annotations: new(service.Annotations),
+		},
+		jobs: make(map[service.AnnotationType]string, 3),
+	}
+	for _, feature := range service.Features() {
+		annotationType := feature.Type
+		if flags&annotationType == 0 {
+			continue
+		}
+		request := new(startRequest)
+		request.Video.S3Object = *object
+		request.ClientRequestToken = token
+		if annotationType == service.ANNOTATION_SHOT_CHANGE {
+			request.SegmentTypes = []string{"SHOT"}
+		}
+		response := new(startResponse)
+		if err := this.do(rekognition_operations[annotationType][0], request, response); err != nil && len(operation.jobs) > 0 {
+			return "", &StartError{err, operation.jobs}
+		} else if err != nil {
+			return "", err
+		}
+		operation.status.Type = append(operation.status.Type, annotationType)
+		operation.status.SetProgress(annotationType, &service.Progress{StartTime: time.Now()})
+		operation.jobs[annotationType] = response.JobId
+	}
+	this.status[operation.status.Name] = operation
+	return operation.status.Name, nil
+}
+
+// Status returns the status of an operation. Rekognition doesn't report
+// progress, so each annotation type is either 0% or 100% complete
+func (this *Client) Status(name string) (*service.Status, error) {
+	operation, exists := this.status[name]
+	if exists == false {
+		return nil, service.ErrNotFound
+	}
+	status := operation.status
+	if status.Done {
+		return status, nil
+	}
+
+	// Check the status of each job. Only the status is needed, so a single
+	// result is requested, and all the results are fetched once every job
+	// has succeeded
+	done := true
+	for _, annotationType := range status.Type {
+		if status.Progress[annotationType].Done {
+			continue
+		}
+		response := new(getResponse)
+		if err := this.do(rekognition_operations[annotationType][1], &getRequest{JobId: operation.jobs[annotationType], MaxResults: 1}, response); err != nil {
+			return nil, err
+		}
+		switch response.JobStatus {
+		case "SUCCEEDED":
+			status.SetProgress(annotationType, &service.Progress{
+				Done:       true,
+				Percent:    100,
+				StartTime:  status.Progress[annotationType].StartTime,
+				UpdateTime: time.Now(),
+			})
+		case "FAILED":
+			return nil, fmt.Errorf("%v: %v: %v", annotationType, ErrJobFailed, response.StatusMessage)
+		default:
+			done = false
+		}
+	}
+
+	// Get the results when all jobs have completed
+	if done {
+		annotations := new(service.Annotations)
+		for _, annotationType := range status.Type {
+			if err := this.results(annotationType, operation.jobs[annotationType], annotations); err != nil {
+				return nil, err
+			}
+		}
+		status.Annotations = annotations
+		status.Done = true
+	}
+	status.Updated = time.Now()
+	return status, nil
+}
+
+// Wait polls an operation at an interval until it has completed
+func (this *Client) Wait(name string, interval time.Duration) (*service.Status, error) {
+	return service.WaitForStatus(func() (*service.Status, error) {
+		return this.Status(name)
+	}, interval)
+}
+
+// Cancel stops tracking an operation. Rekognition jobs cannot be cancelled,
+// so they continue to run
+func (this *Client) Cancel(name string) error {
+	if _, exists := this.status[name]; exists == false {
+		return service.ErrNotFound
+	}
+	delete(this.status, name)
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// do sends a signed request for an operation and decodes the response
+func (this *Client) do(operation string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, this.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", rekognition_CONTENT_TYPE)
+	request.Header.Set("X-Amz-Target", rekognition_TARGET+operation)
+	sign(request, body, this.credentials, this.region, rekognition_SERVICE, time.Now())
+	response, err := this.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return newError(response.StatusCode, data)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// results gets all pages of results for a job and appends the annotations
+func (this *Client) results(annotationType service.AnnotationType, jobId string, annotations *service.Annotations) error {
+	labels := make(map[string]*service.EntityAnnotation)
+	frames := make(map[int64]*service.ExplicitContentAnnotation)
+	request := &getRequest{JobId: jobId, MaxResults: rekognition_MAX_RESULTS, SortBy: "TIMESTAMP"}
+	if annotationType == service.ANNOTATION_SHOT_CHANGE {
+		request.SortBy = ""
+	}
+	for {
+		response := new(getResponse)
+		if err := this.do(rekognition_operations[annotationType][1], request, response); err != nil {
+			return err
+		}
+		for _, detection := range response.Labels {
+			label, exists := labels[detection.Label.Name]
+			if exists == false {
+				label = &service.EntityAnnotation{
+					Entity:     &service.Entity{Description: detection.Label.Name},
+					Categories: make([]*service.Entity, 0, len(detection.Label.Parents)),
+				}
+				for _, parent := range detection.Label.Parents {
+					label.Categories = append(label.Categories, &service.Entity{Description: parent.Name})
+				}
+				labels[detection.Label.Name] = label
+				annotations.FrameLabels = append(annotations.FrameLabels, label)
+			}
+			offset := time.Duration(detection.Timestamp) * time.Millisecond
+			label.Segments = append(label.Segments, &service.Segment{
+				StartOffset: offset,
+				EndOffset:   offset,
+				Confidence:  detection.Label.Confidence / 100,
+			})
+		}
+		for _, detection := range response.ModerationLabels {
+			frame, exists := frames[detection.Timestamp]
+			if exists == false {
+				frame = &service.ExplicitContentAnnotation{Offset: time.Duration(detection.Timestamp) * time.Millisecond}
+				frames[detection.Timestamp] = frame
+				annotations.ExplicitContent = append(annotations.ExplicitContent, frame)
+			}
+			setLikelihood(frame, detection.ModerationLabel.Name, detection.ModerationLabel.ParentName, detection.ModerationLabel.Confidence)
+		}
+		for _, segment := range response.Segments {
+			if segment.Type == "SHOT" {
+				annotations.Shots = append(annotations.Shots, &service.ShotAnnotation{
+					StartOffset: time.Duration(segment.StartTimestampMillis) * time.Millisecond,
+					EndOffset:   time.Duration(segment.EndTimestampMillis) * time.Millisecond,
+				})
+			}
+		}
+		if response.NextToken == "" {
+			break
+		}
+		request.NextToken = response.NextToken
+	}
+	sort.SliceStable(annotations.ExplicitContent, func(i, j int) bool {
+		return annotations.ExplicitContent[i].Offset < annotations.ExplicitContent[j].Offset
+	})
+	return nil
+}
+
+// setLikelihood sets the highest likelihood for the type of explicit content
+// of a moderation label, where confidence is a percentage
+func setLikelihood(frame *service.ExplicitContentAnnotation, name, parent string, confidence float64) {
+	dimension, exists := rekognition_moderation_map[parent]
+	if exists == false {
+		if dimension, exists = rekognition_moderation_map[name]; exists == false {
+			return
+		}
+	}
+	likelihood := service.LIKELIHOOD_VERY_UNLIKELY
+	switch {
+	case confidence >= 90:
+		likelihood = service.LIKELIHOOD_VERY_LIKELY
+	case confidence >= 75:
+		likelihood = service.LIKELIHOOD_LIKELY
+	case confidence >= 50:
+		likelihood = service.LIKELIHOOD_POSSIBLE
+	case confidence >= 25:
+		likelihood = service.LIKELIHOOD_UNLIKELY
+	}
+	switch dimension {
+	case service.EXPLICIT_ADULT:
+		if likelihood > frame.Likelihood {
+			frame.Likelihood = likelihood
+		}
+	case service.EXPLICIT_RACY:
+		if likelihood > frame.Racy {
+			frame.Racy = likelihood
+		}
+	case service.EXPLICIT_VIOLENT:
+		if likelihood > frame.Violent {
+			frame.Violent = likelihood
+		}
+	}
+}
+
+// parseUri returns the bucket and object name for an Amazon S3 URI
+func parseUri(uri string) (*s3Object, error) {
+	if strings.HasPrefix(uri, s3_SCHEME) == false {
+		return nil, ErrInvalidUri
+	}
+	parts := strings.SplitN(strings.TrimPrefix(uri, s3_SCHEME), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrInvalidUri
+	}
+	return &s3Object{Bucket: parts[0], Name: parts[1]}, nil
+}
+
+// newToken returns a random token which identifies an operation, and makes
+// the start requests idempotent
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func newError(statusCode int, data []byte) *Error {
+	var response struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	json.Unmarshal(data, &response)
+	err := &Error{StatusCode: statusCode, Message: response.Message}
+	if err.Message == "" {
+		err.Message = response.MessageUpper
+	}
+	// The type can be prefixed with a namespace
+	if i := strings.LastIndex(response.Type, "#"); i >= 0 {
+		err.Type = response.Type[i+1:]
+	} else {
+		err.Type = response.Type
+	}
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e *StartError) Error() string {
+	started := make([]string, 0, len(e.Started))
+	for annotationType, jobId := range e.Started {
+		started = append(started, fmt.Sprintf("%v=%v", annotationType, jobId))
+	}
+	sort.Strings(started)
+	return fmt.Sprintf("%v (started jobs continue to run: %v)", e.Err, strings.Join(started, ","))
+}
+
+func (e *Error) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("Rekognition: %v: %v", http.StatusText(e.StatusCode), e.Message)
+	}
+	return fmt.Sprintf("Rekognition: %v: %v", e.Type, e.Message)
+}
@@ -0,0 +1,237 @@
+package rekognition
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// STUB SERVER
+
+// stub is a local Rekognition API, where each operation returns the next of
+// its responses, and the last response is repeated
+type stub struct {
+	sync.Mutex
+	*httptest.Server
+	responses map[string][]string
+	requests  map[string][]map[string]interface{}
+}
+
+func newStub(t *testing.T, responses map[string][]string) *stub {
+	this := &stub{responses: responses, requests: make(map[string][]map[string]interface{})}
+	this.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		this.Lock()
+		defer this.Unlock()
+		if strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") == false {
+			t.Errorf("Unsigned request: %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Content-Type") != rekognition_CONTENT_TYPE {
+			t.Errorf("Unexpected content type: %q", r.Header.Get("Content-Type"))
+		}
+		operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), rekognition_TARGET)
+		body, _ := ioutil.ReadAll(r.Body)
+		request := make(map[string]interface{})
+		json.Unmarshal(body, &request)
+		n := len(this.requests[operation])
+		this.requests[operation] = append(this.requests[operation], request)
+		responses, exists := this.responses[operation]
+		if exists == false || len(responses) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.rekognition#InvalidParameterException","message":"Unknown operation"}`))
+			return
+		}
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		if strings.HasPrefix(responses[n], "!") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(responses[n][1:]))
+			return
+		}
+		w.Write([]byte(responses[n]))
+	}))
+	return this
+}
+
+func (this *stub) client(t *testing.T) *Client {
+	client, err := New("eu-west-1", &Credentials{AccessKeyId: "AKID", SecretAccessKey: "secret"}, WithEndpoint(this.URL), WithClient(this.Server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestAnnotate(t *testing.T) {
+	stub := newStub(t, map[string][]string{
+		"StartLabelDetection":    {`{"JobId":"labels"}`},
+		"StartContentModeration": {`{"JobId":"moderation"}`},
+		"StartSegmentDetection":  {`{"JobId":"segments"}`},
+		"GetLabelDetection": {
+			`{"JobStatus":"IN_PROGRESS"}`,
+			`{"JobStatus":"SUCCEEDED"}`,
+			`{"JobStatus":"SUCCEEDED","NextToken":"page2","Labels":[{"Timestamp":0,"Label":{"Name":"Dog","Confidence":90,"Parents":[{"Name":"Animal"}]}}]}`,
+			`{"JobStatus":"SUCCEEDED","Labels":[{"Timestamp":1000,"Label":{"Name":"Dog","Confidence":80}}]}`,
+		},
+		"GetContentModeration": {`{"JobStatus":"SUCCEEDED","ModerationLabels":[{"Timestamp":500,"ModerationLabel":{"Name":"Graphic Violence","ParentName":"Violence","Confidence":95}},{"Timestamp":500,"ModerationLabel":{"Name":"Suggestive","Confidence":60}}]}`},
+		"GetSegmentDetection":  {`{"JobStatus":"SUCCEEDED","Segments":[{"Type":"SHOT","StartTimestampMillis":0,"EndTimestampMillis":2000},{"Type":"TECHNICAL_CUE","StartTimestampMillis":0,"EndTimestampMillis":100}]}`},
+	})
+	defer stub.Close()
+	client := stub.client(t)
+
+	name, err := client.Annotate("s3://bucket/folder/video.mp4", service.ANNOTATION_LABEL|service.ANNOTATION_EXPLICIT_CONTENT|service.ANNOTATION_SHOT_CHANGE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for operation, requests := range stub.requests {
+		if strings.HasPrefix(operation, "Start") == false {
+			continue
+		}
+		video := requests[0]["Video"].(map[string]interface{})["S3Object"].(map[string]interface{})
+		if video["Bucket"] != "bucket" || video["Name"] != "folder/video.mp4" {
+			t.Errorf("%v: Unexpected video %v", operation, video)
+		}
+	}
+	if segmentTypes := stub.requests["StartSegmentDetection"][0]["SegmentTypes"]; len(segmentTypes.([]interface{})) != 1 {
+		t.Errorf("Unexpected segment types %v", segmentTypes)
+	}
+
+	// The first poll is in progress
+	status, err := client.Status(name)
+	if err != nil {
+		t.Fatal(err)
+	} else if status.Done {
+		t.Fatal("Expected operation in progress")
+	}
+
+	// The second poll has succeeded, and the results are fetched
+	status, err = client.Wait(name, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PercentComplete() != 100 {
+		t.Errorf("Unexpected percent complete %v", status.PercentComplete())
+	}
+
+	// Polls request a single result, and results are fetched in pages
+	requests := stub.requests["GetLabelDetection"]
+	if len(requests) != 4 {
+		t.Fatalf("Expected 4 requests, got %v", len(requests))
+	}
+	for i, request := range requests {
+		maxResults := request["MaxResults"].(float64)
+		if i < 2 && maxResults != 1 {
+			t.Errorf("Poll %v: Unexpected MaxResults %v", i, maxResults)
+		} else if i >= 2 && maxResults != rekognition_MAX_RESULTS {
+			t.Errorf("Page %v: Unexpected MaxResults %v", i, maxResults)
+		}
+	}
+	if requests[2]["NextToken"] != nil || requests[3]["NextToken"] != "page2" {
+		t.Errorf("Unexpected NextToken %v, %v", requests[2]["NextToken"], requests[3]["NextToken"])
+	}
+
+	// Labels from both pages are merged into one frame label
+	annotations := status.Annotations
+	if len(annotations.FrameLabels) != 1 {
+		t.Fatalf("Expected one label, got %v", len(annotations.FrameLabels))
+	} else if label := annotations.FrameLabels[0]; len(label.Segments) != 2 || label.Categories[0].Description != "Animal" || label.Segments[0].Confidence != 0.9 || label.Segments[1].StartOffset != time.Second {
+		t.Errorf("Unexpected label %v", label)
+	}
+	if len(annotations.ExplicitContent) != 1 {
+		t.Fatalf("Expected one frame, got %v", len(annotations.ExplicitContent))
+	} else if frame := annotations.ExplicitContent[0]; frame.Offset != 500*time.Millisecond || frame.Violent != service.LIKELIHOOD_VERY_LIKELY || frame.Racy != service.LIKELIHOOD_POSSIBLE || frame.Likelihood != service.LIKELIHOOD_UNSPECIFIED {
+		t.Errorf("Unexpected frame %v", frame)
+	}
+	if len(annotations.Shots) != 1 || annotations.Shots[0].EndOffset != 2*time.Second {
+		t.Errorf("Unexpected shots %v", annotations.Shots)
+	}
+
+	// Cancel stops tracking the operation
+	if err := client.Cancel(name); err != nil {
+		t.Error(err)
+	} else if _, err := client.Status(name); err != service.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAnnotateInvalid(t *testing.T) {
+	stub := newStub(t, nil)
+	defer stub.Close()
+	client := stub.client(t)
+	if _, err := client.Annotate("gs://bucket/video.mp4", service.ANNOTATION_LABEL); err != ErrInvalidUri {
+		t.Errorf("Expected ErrInvalidUri, got %v", err)
+	}
+	if _, err := client.Annotate("s3://bucket/", service.ANNOTATION_LABEL); err != ErrInvalidUri {
+		t.Errorf("Expected ErrInvalidUri, got %v", err)
+	}
+	if _, err := client.Annotate("s3://bucket/video.mp4", service.ANNOTATION_OBJECT_TRACKING); err == nil || strings.Contains(err.Error(), ErrNotSupported.Error()) == false {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+	if _, err := client.AnnotateWithConfig("s3://bucket/video.mp4", service.ANNOTATION_LABEL, &service.Config{Segments: []*service.Segment{{EndOffset: time.Second}}}); err == nil {
+		t.Error("Expected error for segments")
+	}
+	if len(stub.requests) != 0 {
+		t.Errorf("Unexpected requests %v", stub.requests)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	stub := newStub(t, map[string][]string{
+		"StartLabelDetection": {`!{"__type":"com.amazonaws.rekognition#AccessDeniedException","Message":"Denied"}`},
+	})
+	defer stub.Close()
+	_, err := stub.client(t).Annotate("s3://bucket/video.mp4", service.ANNOTATION_LABEL)
+	if apiError, ok := err.(*Error); ok == false {
+		t.Fatalf("Expected *Error, got %v", err)
+	} else if apiError.StatusCode != http.StatusBadRequest || apiError.Type != "AccessDeniedException" || apiError.Message != "Denied" {
+		t.Errorf("Unexpected error %+v", apiError)
+	} else if apiError.Error() != "Rekognition: AccessDeniedException: Denied" {
+		t.Errorf("Unexpected message %q", apiError.Error())
+	}
+}
+
+func TestStartError(t *testing.T) {
+	stub := newStub(t, map[string][]string{
+		"StartSegmentDetection":  {`{"JobId":"segments"}`},
+		"StartLabelDetection":    {`!{"__type":"LimitExceededException","message":"Too many jobs"}`},
+		"StartContentModeration": {`{"JobId":"moderation"}`},
+	})
+	defer stub.Close()
+	_, err := stub.client(t).Annotate("s3://bucket/video.mp4", service.ANNOTATION_SHOT_CHANGE|service.ANNOTATION_LABEL|service.ANNOTATION_EXPLICIT_CONTENT)
+	if startError, ok := err.(*StartError); ok == false {
+		t.Fatalf("Expected *StartError, got %v", err)
+	} else if len(startError.Started) != 1 || startError.Started[service.ANNOTATION_SHOT_CHANGE] != "segments" {
+		t.Errorf("Unexpected started jobs %v", startError.Started)
+	} else if apiError, ok := startError.Err.(*Error); ok == false || apiError.Type != "LimitExceededException" {
+		t.Errorf("Unexpected error %v", startError.Err)
+	}
+	if len(stub.requests["StartContentModeration"]) != 0 {
+		t.Error("Expected no jobs to start after the failure")
+	}
+}
+
+func TestJobFailed(t *testing.T) {
+	stub := newStub(t, map[string][]string{
+		"StartLabelDetection": {`{"JobId":"labels"}`},
+		"GetLabelDetection":   {`{"JobStatus":"FAILED","StatusMessage":"Unsupported codec"}`},
+	})
+	defer stub.Close()
+	client := stub.client(t)
+	name, err := client.Annotate("s3://bucket/video.mp4", service.ANNOTATION_LABEL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Status(name); err == nil || strings.Contains(err.Error(), ErrJobFailed.Error()) == false || strings.Contains(err.Error(), "Unsupported codec") == false {
+		t.Errorf("Expected ErrJobFailed, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package rekognition
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	sign_ALGORITHM   = "AWS4-HMAC-SHA256"
+	sign_DATE_FORMAT = "20060102T150405Z"
+	sign_TERMINATOR  = "aws4_request"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// sign adds AWS Signature Version 4 headers to a request, signing the host
+// and all other headers which are set. The path must not need encoding and
+// the request must not have query parameters
+func sign(request *http.Request, body []byte, credentials *Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	date := now.Format(sign_DATE_FORMAT)
+	request.Header.Set("X-Amz-Date", date)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	// Canonical headers are lowercase, sorted and include the host
+	headers := map[string]string{"host": request.URL.Host}
+	for key, values := range request.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Canonical request
+	path := request.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	payload := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		request.Method,
+		path,
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	// String to sign and signature
+	scope := strings.Join([]string{date[:8], region, service, sign_TERMINATOR}, "/")
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{sign_ALGORITHM, date, scope, hex.EncodeToString(hash[:])}, "\n")
+	key := signingKey(credentials.SecretAccessKey, date[:8], region, service)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("%v Credential=%v/%v, SignedHeaders=%v, Signature=%v", sign_ALGORITHM, credentials.AccessKeyId, scope, signedHeaders, signature))
+}
+
+// signingKey returns the key derived from the secret access key for a date
+// such as 20150830, region and service
+func signingKey(secret, date, region, service string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{date, region, service, sign_TERMINATOR} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func hmacSHA256(key []byte, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
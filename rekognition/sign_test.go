@@ -0,0 +1,66 @@
+package rekognition
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Requests from the AWS Signature Version 4 test suite, which are signed
+// with the example credentials for us-east-1 and a service named service
+func TestSign(t *testing.T) {
+	credentials := &Credentials{AccessKeyId: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now, err := time.Parse(sign_DATE_FORMAT, "20150830T123600Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		method        string
+		headers       map[string]string
+		body          string
+		authorization string
+	}{
+		{"get-vanilla", "GET", nil, "", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", "POST", nil, "", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-header-key-sort", "POST", map[string]string{"My-Header1": "value1"}, "", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;my-header1;x-amz-date, Signature=c5410059b04c1ee005303aed430f6e6645f61f4dc9e1461ec8f8916fdf18852c"},
+		{"post-x-www-form-urlencoded", "POST", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "Param1=value1", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for _, test := range tests {
+		request, err := http.NewRequest(test.method, "https://example.amazonaws.com/", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range test.headers {
+			request.Header.Set(key, value)
+		}
+		sign(request, []byte(test.body), credentials, "us-east-1", "service", now)
+		if authorization := request.Header.Get("Authorization"); authorization != test.authorization {
+			t.Errorf("%v: Expected %q, got %q", test.name, test.authorization, authorization)
+		}
+		if date := request.Header.Get("X-Amz-Date"); date != "20150830T123600Z" {
+			t.Errorf("%v: Unexpected date %q", test.name, date)
+		}
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	request, _ := http.NewRequest("POST", "https://example.amazonaws.com/", nil)
+	sign(request, nil, &Credentials{AccessKeyId: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}, "us-east-1", "service", time.Now())
+	if request.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("Missing security token")
+	}
+	if strings.Contains(request.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,") == false {
+		t.Errorf("Security token not signed: %v", request.Header.Get("Authorization"))
+	}
+}
+
+// Example from the AWS documentation for deriving a signing key
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"; hex.EncodeToString(key) != expected {
+		t.Errorf("Expected %v, got %x", expected, key)
+	}
+}
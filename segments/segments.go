@@ -0,0 +1,264 @@
+package segments
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Set is an ordered list of ranges which do not overlap or touch. Use New or
+// one of the From functions to create a set
+type Set []*Range
+
+// Range is a start and end offset, where the end offset is not included.
+// The confidence of merged ranges is the highest confidence, and for
+// intersections the lowest confidence
+type Range struct {
+	Start      time.Duration
+	End        time.Duration
+	Confidence float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// FrameWidth is the width of a range for a frame label, so that frames
+	// are not ignored as empty ranges. Use a tolerance to join frames
+	FrameWidth = time.Millisecond
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// New returns a set from ranges in any order, merging ranges which overlap or
+// touch and ignoring empty ranges
+func New(ranges ...*Range) Set {
+	sorted := make([]*Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r != nil && r.End > r.Start {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	set := make(Set, 0, len(sorted))
+	for _, r := range sorted {
+		if last := len(set) - 1; last >= 0 && r.Start <= set[last].End {
+			if r.End > set[last].End {
+				set[last].End = r.End
+			}
+			if r.Confidence > set[last].Confidence {
+				set[last].Confidence = r.Confidence
+			}
+		} else {
+			set = append(set, &Range{r.Start, r.End, r.Confidence})
+		}
+	}
+	return set
+}
+
+// FromSegments returns a set from label segments
+func FromSegments(segments []*service.Segment) Set {
+	ranges := make([]*Range, len(segments))
+	for i, segment := range segments {
+		ranges[i] = &Range{segment.StartOffset, segment.EndOffset, segment.Confidence}
+	}
+	return New(ranges...)
+}
+
+// FromShots returns a set from shot annotations
+func FromShots(shots []*service.ShotAnnotation) Set {
+	ranges := make([]*Range, len(shots))
+	for i, shot := range shots {
+		ranges[i] = &Range{Start: shot.StartOffset, End: shot.EndOffset}
+	}
+	return New(ranges...)
+}
+
+// FromOffsets returns a set from points in time such as frame labels or
+// explicit content frames, where each point becomes a range with the given
+// width. Use FillGaps to join points which are close together
+func FromOffsets(offsets []time.Duration, width time.Duration) Set {
+	ranges := make([]*Range, len(offsets))
+	for i, offset := range offsets {
+		ranges[i] = &Range{Start: offset, End: offset + width}
+	}
+	return New(ranges...)
+}
+
+// Union returns the ranges which are in either set
+func (this Set) Union(other Set) Set {
+	ranges := make([]*Range, 0, len(this)+len(other))
+	ranges = append(ranges, this...)
+	ranges = append(ranges, other...)
+	return New(ranges...)
+}
+
+// Intersect returns the ranges which are in both sets
+func (this Set) Intersect(other Set) Set {
+	set := make(Set, 0)
+	for i, j := 0, 0; i < len(this) && j < len(other); {
+		a, b := this[i], other[j]
+		start, end := maxDuration(a.Start, b.Start), minDuration(a.End, b.End)
+		if start < end {
+			set = append(set, &Range{start, end, minFloat(a.Confidence, b.Confidence)})
+		}
+		if a.End < b.End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return set
+}
+
+// Difference returns the ranges which are in this set but not in the other
+func (this Set) Difference(other Set) Set {
+	set := make(Set, 0)
+	j := 0
+	for _, r := range this {
+		start := r.Start
+		for ; j < len(other) && other[j].End <= start; j++ {
+		}
+		for k := j; k < len(other) && other[k].Start < r.End; k++ {
+			if other[k].Start > start {
+				set = append(set, &Range{start, other[k].Start, r.Confidence})
+			}
+			start = maxDuration(start, other[k].End)
+		}
+		if start < r.End {
+			set = append(set, &Range{start, r.End, r.Confidence})
+		}
+	}
+	return set
+}
+
+// FillGaps returns a set where ranges separated by a gap no longer than the
+// tolerance are joined into a single range
+func (this Set) FillGaps(tolerance time.Duration) Set {
+	set := make(Set, 0, len(this))
+	for _, r := range this {
+		if last := len(set) - 1; last >= 0 && r.Start-set[last].End <= tolerance {
+			set[last].End = r.End
+			set[last].Confidence = maxFloat(set[last].Confidence, r.Confidence)
+		} else {
+			set = append(set, &Range{r.Start, r.End, r.Confidence})
+		}
+	}
+	return set
+}
+
+// MinDuration returns a set without the ranges shorter than a duration
+func (this Set) MinDuration(duration time.Duration) Set {
+	set := make(Set, 0, len(this))
+	for _, r := range this {
+		if r.Duration() >= duration {
+			set = append(set, &Range{r.Start, r.End, r.Confidence})
+		}
+	}
+	return set
+}
+
+// Coverage returns the total duration of the ranges in the set
+func (this Set) Coverage() time.Duration {
+	var total time.Duration
+	for _, r := range this {
+		total += r.Duration()
+	}
+	return total
+}
+
+// Contains returns true if an offset is within one of the ranges
+func (this Set) Contains(offset time.Duration) bool {
+	i := sort.Search(len(this), func(i int) bool {
+		return this[i].End > offset
+	})
+	return i < len(this) && this[i].Start <= offset
+}
+
+// Segments returns the ranges as label segments, for example for export
+func (this Set) Segments() []*service.Segment {
+	segments := make([]*service.Segment, len(this))
+	for i, r := range this {
+		segments[i] = &service.Segment{StartOffset: r.Start, EndOffset: r.End, Confidence: r.Confidence}
+	}
+	return segments
+}
+
+// MergeLabel returns a copy of a label where the segments are merged into
+// continuous ranges, joining gaps no longer than the tolerance and then
+// removing ranges shorter than the minimum duration. Frame labels, where
+// segments start and end at the same offset, are ranges of FrameWidth
+func MergeLabel(label *service.EntityAnnotation, tolerance, minDuration time.Duration) *service.EntityAnnotation {
+	ranges := make([]*Range, len(label.Segments))
+	for i, segment := range label.Segments {
+		ranges[i] = &Range{segment.StartOffset, segment.EndOffset, segment.Confidence}
+		if segment.EndOffset == segment.StartOffset {
+			ranges[i].End += FrameWidth
+		}
+	}
+	return &service.EntityAnnotation{
+		Entity:     label.Entity,
+		Categories: label.Categories,
+		Segments:   New(ranges...).FillGaps(tolerance).MinDuration(minDuration).Segments(),
+	}
+}
+
+// Duration returns the length of the range
+func (this *Range) Duration() time.Duration {
+	return this.End - this.Start
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (r *Range) String() string {
+	return fmt.Sprintf("[%v,%v)", r.Start, r.End)
+}
+
+func (s Set) String() string {
+	ranges := make([]string, len(s))
+	for i, r := range s {
+		ranges[i] = r.String()
+	}
+	return "{" + strings.Join(ranges, " ") + "}"
+}
@@ -0,0 +1,219 @@
+package segments
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		ranges []*Range
+		set    string
+	}{
+		{nil, "{}"},
+		{[]*Range{nil, {Start: time.Second, End: time.Second}}, "{}"},
+		{seconds(3, 4, 0, 1), "{[0s,1s) [3s,4s)}"},
+		{seconds(0, 2, 1, 3), "{[0s,3s)}"},
+		{seconds(0, 1, 1, 2), "{[0s,2s)}"},
+		{seconds(0, 5, 1, 2), "{[0s,5s)}"},
+		{seconds(2, 1, 3, 4), "{[3s,4s)}"},
+	}
+	for _, test := range tests {
+		if set := New(test.ranges...); set.String() != test.set {
+			t.Errorf("%v: Expected %v, got %v", test.ranges, test.set, set)
+		}
+	}
+}
+
+func TestNewConfidence(t *testing.T) {
+	set := New(&Range{0, 2 * time.Second, 0.5}, &Range{time.Second, 3 * time.Second, 0.9}, &Range{5 * time.Second, 6 * time.Second, 0.1})
+	if len(set) != 2 || set[0].Confidence != 0.9 || set[1].Confidence != 0.1 {
+		t.Errorf("Unexpected set %v", set)
+	}
+}
+
+func TestAlgebra(t *testing.T) {
+	tests := []struct {
+		a, b                         Set
+		union, intersect, difference string
+	}{
+		{New(), New(), "{}", "{}", "{}"},
+		{New(seconds(0, 2)...), New(), "{[0s,2s)}", "{}", "{[0s,2s)}"},
+		{New(), New(seconds(0, 2)...), "{[0s,2s)}", "{}", "{}"},
+		{New(seconds(0, 2)...), New(seconds(1, 3)...), "{[0s,3s)}", "{[1s,2s)}", "{[0s,1s)}"},
+		{New(seconds(0, 2)...), New(seconds(2, 3)...), "{[0s,3s)}", "{}", "{[0s,2s)}"},
+		{New(seconds(0, 10)...), New(seconds(2, 3, 5, 6)...), "{[0s,10s)}", "{[2s,3s) [5s,6s)}", "{[0s,2s) [3s,5s) [6s,10s)}"},
+		{New(seconds(2, 3, 5, 6)...), New(seconds(0, 10)...), "{[0s,10s)}", "{[2s,3s) [5s,6s)}", "{}"},
+		{New(seconds(0, 4, 6, 10)...), New(seconds(3, 7)...), "{[0s,10s)}", "{[3s,4s) [6s,7s)}", "{[0s,3s) [7s,10s)}"},
+	}
+	for _, test := range tests {
+		if union := test.a.Union(test.b); union.String() != test.union {
+			t.Errorf("%v | %v: Expected %v, got %v", test.a, test.b, test.union, union)
+		}
+		if intersect := test.a.Intersect(test.b); intersect.String() != test.intersect {
+			t.Errorf("%v & %v: Expected %v, got %v", test.a, test.b, test.intersect, intersect)
+		}
+		if difference := test.a.Difference(test.b); difference.String() != test.difference {
+			t.Errorf("%v - %v: Expected %v, got %v", test.a, test.b, test.difference, difference)
+		}
+	}
+}
+
+func TestIntersectConfidence(t *testing.T) {
+	a := New(&Range{0, 2 * time.Second, 0.9})
+	b := New(&Range{time.Second, 3 * time.Second, 0.4})
+	if set := a.Intersect(b); len(set) != 1 || set[0].Confidence != 0.4 {
+		t.Errorf("Unexpected intersection %v", set)
+	}
+	if set := a.Difference(b); len(set) != 1 || set[0].Confidence != 0.9 {
+		t.Errorf("Unexpected difference %v", set)
+	}
+}
+
+// Compare the set operations against membership of each offset, for
+// random sets with offsets in nanoseconds
+func TestAlgebraRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		a, b := randomSet(random), randomSet(random)
+		union, intersect, difference := a.Union(b), a.Intersect(b), a.Difference(b)
+		var coverage time.Duration
+		for offset := time.Duration(0); offset < 130; offset++ {
+			inA, inB := contains(a, offset), contains(b, offset)
+			if inA {
+				coverage++
+			}
+			if a.Contains(offset) != inA {
+				t.Fatalf("%v: Unexpected contains %v", a, offset)
+			}
+			if union.Contains(offset) != (inA || inB) {
+				t.Fatalf("%v | %v: Unexpected %v at %v", a, b, union, offset)
+			}
+			if intersect.Contains(offset) != (inA && inB) {
+				t.Fatalf("%v & %v: Unexpected %v at %v", a, b, intersect, offset)
+			}
+			if difference.Contains(offset) != (inA && !inB) {
+				t.Fatalf("%v - %v: Unexpected %v at %v", a, b, difference, offset)
+			}
+		}
+		if a.Coverage() != coverage {
+			t.Fatalf("%v: Expected coverage %v, got %v", a, coverage, a.Coverage())
+		}
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	set := New(seconds(0, 5, 7, 9, 20, 21)...)
+	tests := []struct {
+		tolerance time.Duration
+		set       string
+	}{
+		{0, "{[0s,5s) [7s,9s) [20s,21s)}"},
+		{time.Second, "{[0s,5s) [7s,9s) [20s,21s)}"},
+		{2 * time.Second, "{[0s,9s) [20s,21s)}"},
+		{11 * time.Second, "{[0s,21s)}"},
+	}
+	for _, test := range tests {
+		if filled := set.FillGaps(test.tolerance); filled.String() != test.set {
+			t.Errorf("%v: Expected %v, got %v", test.tolerance, test.set, filled)
+		}
+	}
+	if set.String() != "{[0s,5s) [7s,9s) [20s,21s)}" {
+		t.Errorf("Unexpected change to set %v", set)
+	}
+}
+
+func TestMinDuration(t *testing.T) {
+	set := New(seconds(0, 5, 7, 9, 20, 21)...)
+	if filtered := set.MinDuration(2 * time.Second); filtered.String() != "{[0s,5s) [7s,9s)}" {
+		t.Errorf("Unexpected set %v", filtered)
+	}
+	if coverage := set.Coverage(); coverage != 8*time.Second {
+		t.Errorf("Unexpected coverage %v", coverage)
+	}
+}
+
+func TestFromOffsets(t *testing.T) {
+	offsets := []time.Duration{time.Second, 2 * time.Second, 10 * time.Second}
+	if set := FromOffsets(offsets, time.Second); set.String() != "{[1s,3s) [10s,11s)}" {
+		t.Errorf("Unexpected set %v", set)
+	}
+	if set := FromOffsets(offsets, 0); len(set) != 0 {
+		t.Errorf("Unexpected set %v", set)
+	}
+}
+
+func TestFromShots(t *testing.T) {
+	shots := []*service.ShotAnnotation{
+		{StartOffset: 2 * time.Second, EndOffset: 4 * time.Second},
+		{StartOffset: 0, EndOffset: 2 * time.Second},
+	}
+	if set := FromShots(shots); set.String() != "{[0s,4s)}" {
+		t.Errorf("Unexpected set %v", set)
+	}
+}
+
+func TestMergeLabel(t *testing.T) {
+	label := &service.EntityAnnotation{
+		Entity: &service.Entity{Description: "dog"},
+		Segments: []*service.Segment{
+			{StartOffset: 0, EndOffset: 2 * time.Second, Confidence: 0.5},
+			{StartOffset: 2500 * time.Millisecond, EndOffset: 4 * time.Second, Confidence: 0.8},
+			{StartOffset: 10 * time.Second, EndOffset: 10 * time.Second, Confidence: 0.9},
+		},
+	}
+	merged := MergeLabel(label, time.Second, 0)
+	if merged.Entity != label.Entity || len(merged.Segments) != 2 {
+		t.Fatalf("Unexpected label %v", merged)
+	}
+	if segment := merged.Segments[0]; segment.StartOffset != 0 || segment.EndOffset != 4*time.Second || segment.Confidence != 0.8 {
+		t.Errorf("Unexpected segment %v", segment)
+	}
+	if segment := merged.Segments[1]; segment.StartOffset != 10*time.Second || segment.EndOffset != 10*time.Second+FrameWidth {
+		t.Errorf("Unexpected frame segment %v", segment)
+	}
+	if merged := MergeLabel(label, time.Second, time.Second); len(merged.Segments) != 1 {
+		t.Errorf("Unexpected segments %v", merged.Segments)
+	}
+	if len(label.Segments) != 3 {
+		t.Errorf("Unexpected change to label %v", label)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// seconds returns ranges from pairs of start and end offsets in seconds
+func seconds(values ...int) []*Range {
+	ranges := make([]*Range, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		ranges = append(ranges, &Range{Start: time.Duration(values[i]) * time.Second, End: time.Duration(values[i+1]) * time.Second})
+	}
+	return ranges
+}
+
+// randomSet returns a set of up to eight short ranges
+func randomSet(random *rand.Rand) Set {
+	ranges := make([]*Range, random.Intn(8))
+	for i := range ranges {
+		start := time.Duration(random.Intn(100))
+		ranges[i] = &Range{Start: start, End: start + time.Duration(random.Intn(20))}
+	}
+	return New(ranges...)
+}
+
+// contains returns true if an offset is in any range of a set
+func contains(set Set, offset time.Duration) bool {
+	for _, r := range set {
+		if offset >= r.Start && offset < r.End {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Annotator is implemented by video analysis providers, which return
+// annotations in the same model as the Video Intelligence API
+type Annotator interface {
+	// Annotate starts annotating a video and returns the operation name
+	Annotate(uri string, flags AnnotationType) (string, error)
+
+	// AnnotateWithConfig starts annotating a video with detection
+	// parameters, which can be nil
+	AnnotateWithConfig(uri string, flags AnnotationType, config *Config) (string, error)
+
+	// Status returns the current status of an operation
+	Status(name string) (*Status, error)
+
+	// Wait polls an operation at an interval until it has completed
+	Wait(name string, interval time.Duration) (*Status, error)
+
+	// Cancel requests that an operation is cancelled, and stops tracking it
+	Cancel(name string) error
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	// Check the service implements the interface
+	_ Annotator = (*Service)(nil)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WaitForStatus calls a status function at an interval until the operation
+// has completed, for implementing Annotator.Wait
+func WaitForStatus(status func() (*Status, error), interval time.Duration) (*Status, error) {
+	for {
+		if status, err := status(); err != nil {
+			return nil, err
+		} else if status.Done {
+			return status, nil
+		}
+		time.Sleep(interval)
+	}
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -11,7 +10,6 @@ import (
 	"time"
 
 	v1 "github.com/djthorpe/VideoIntelligence/videointelligence/v1"
-	v1beta2 "github.com/djthorpe/VideoIntelligence/videointelligence/v1beta2"
 	oauth2 "golang.org/x/oauth2"
 	google "golang.org/x/oauth2/google"
 )
@@ -21,9 +19,17 @@ import (
 
 // Service defines the client for the Video Intellgence API
 type Service struct {
-	videos *v1beta2.Service
-	ops    *v1.Service
-	status map[string]*Status
+	client   *http.Client
+	version  Version
+	endpoint string
+	backend  backend
+	status   map[string]*Status
+	cache    *Cache
+	ledger   *Ledger
+	pricing  *EstimateConfig
+	budget   *BudgetPolicy
+	spent    float64
+	pending  float64
 }
 
 // Status defines the current operation status
@@ -35,14 +41,30 @@ type Status struct {
 	Progress    map[AnnotationType]*Progress
 	Updated     time.Time
 	Annotations *Annotations
+	Cached      bool
+	key         string
+	flags       AnnotationType
+	config      *Config
+	estimate    *VideoEstimate
+	rates       map[AnnotationType]*progressRate
+	err         error
 }
 
-// Annotations
+// Annotations. Features which are not built in store their annotations in
+// Extensions, keyed by feature name
 type Annotations struct {
 	Shots           []*ShotAnnotation
 	ShotLabels      []*EntityAnnotation
 	SegmentLabels   []*EntityAnnotation
+	FrameLabels     []*EntityAnnotation
 	ExplicitContent []*ExplicitContentAnnotation
+	ObjectTracks    []*ObjectTrack
+	Text            []*TextAnnotation
+	Speech          []*SpeechTranscription
+	People          []*PersonAnnotation
+	Faces           []*FaceAnnotation
+	Logos           []*LogoAnnotation
+	Extensions      map[string]interface{} `json:",omitempty"`
 }
 
 // ShotAnnotation is data around detecting the start and end of shots in the video
@@ -51,19 +73,166 @@ type ShotAnnotation struct {
 	EndOffset   time.Duration
 }
 
-// ExplicitContentAnnotation is data around detecting explicit content within the video
+// ExplicitContentAnnotation is data around detecting explicit content within the video.
+// Likelihood is for pornographic or adult content. The spoof, medical,
+// violent and racy likelihoods are only returned by the v1beta1 API, and are
+// otherwise LIKELIHOOD_UNSPECIFIED
 type ExplicitContentAnnotation struct {
 	Offset     time.Duration
 	Likelihood LikelihoodType
+	Spoof      LikelihoodType `json:",omitempty"`
+	Medical    LikelihoodType `json:",omitempty"`
+	Violent    LikelihoodType `json:",omitempty"`
+	Racy       LikelihoodType `json:",omitempty"`
 }
 
-// EntityAnnotation is data around the classification of objects in the video
+// EntityAnnotation is data around the classification of objects in the video.
+// For frame labels, each segment is a single frame with the same start and
+// end offset
 type EntityAnnotation struct {
 	Entity     *Entity
 	Categories []*Entity
 	Segments   []*Segment
 }
 
+// ObjectTrack is an object tracked through a segment of the video, with
+// the bounding box of the object in each frame
+type ObjectTrack struct {
+	Entity      *Entity
+	Confidence  float64
+	StartOffset time.Duration
+	EndOffset   time.Duration
+	Frames      []*ObjectFrame
+}
+
+// ObjectFrame is the bounding box of a tracked object at an offset
+type ObjectFrame struct {
+	Offset time.Duration
+	Box    *BoundingBox
+}
+
+// BoundingBox is a box with coordinates normalised to the range 0 to 1,
+// relative to the top left of the frame
+type BoundingBox struct {
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+}
+
+// TextAnnotation is text detected on screen, with each segment of the video
+// where it appears
+type TextAnnotation struct {
+	Text     string
+	Segments []*TextSegment
+}
+
+// TextSegment is a segment where text appears, with the position of the text
+// in each frame
+type TextSegment struct {
+	StartOffset time.Duration
+	EndOffset   time.Duration
+	Confidence  float64
+	Frames      []*TextFrame
+}
+
+// TextFrame is the rotated bounding polygon of text at an offset. The
+// vertices are clockwise from the top left corner of the text, so the
+// polygon is rotated with the text
+type TextFrame struct {
+	Offset  time.Duration
+	Polygon []*Vertex
+}
+
+// Vertex is a point with coordinates normalised to the range 0 to 1,
+// relative to the top left of the frame
+type Vertex struct {
+	X float64
+	Y float64
+}
+
+// SpeechTranscription is the transcript of a section of audio, with the
+// most likely alternative first
+type SpeechTranscription struct {
+	LanguageCode string
+	Alternatives []*SpeechAlternative
+}
+
+// SpeechAlternative is a possible transcript, with the time offset of each
+// word
+type SpeechAlternative struct {
+	Transcript string
+	Confidence float64
+	Words      []*Word
+}
+
+// Word is a spoken word. Speaker is numbered from 1 when speaker diarization
+// is enabled, or otherwise zero
+type Word struct {
+	StartOffset time.Duration
+	EndOffset   time.Duration
+	Word        string
+	Confidence  float64
+	Speaker     int64 `json:",omitempty"`
+}
+
+// PersonAnnotation is a person detected in the video, with a track for
+// each segment where they appear
+type PersonAnnotation struct {
+	Tracks []*Track
+}
+
+// FaceAnnotation is a face detected in the video, with a track for each
+// segment where it appears and a JPEG thumbnail when returned
+type FaceAnnotation struct {
+	Tracks    []*Track
+	Thumbnail []byte `json:",omitempty"`
+}
+
+// LogoAnnotation is a logo recognised in the video, with a track for each
+// appearance and the segments where it appears
+type LogoAnnotation struct {
+	Entity   *Entity
+	Tracks   []*Track
+	Segments []*Segment
+}
+
+// Track is an object tracked through a segment of the video, with the
+// attributes of the object and its bounding box in each frame
+type Track struct {
+	StartOffset time.Duration
+	EndOffset   time.Duration
+	Confidence  float64
+	Attributes  []*Attribute
+	Frames      []*TrackFrame
+}
+
+// TrackFrame is the bounding box of a tracked object at an offset, with
+// attributes and landmarks when requested
+type TrackFrame struct {
+	Offset     time.Duration
+	Box        *BoundingBox
+	Attributes []*Attribute `json:",omitempty"`
+	Landmarks  []*Landmark  `json:",omitempty"`
+}
+
+// Attribute is a property of a tracked object, such as clothing or
+// expression
+type Attribute struct {
+	Name       string
+	Value      string
+	Confidence float64
+}
+
+// Landmark is a named point of a tracked object, such as a joint, with
+// coordinates normalised to the range 0 to 1
+type Landmark struct {
+	Name       string
+	X          float64
+	Y          float64
+	Confidence float64
+}
+
 // Segment is start and end offset, with confidence
 type Segment struct {
 	StartOffset time.Duration
@@ -92,6 +261,9 @@ type AnnotationType uint
 // Likelihood
 type LikelihoodType uint
 
+// ExplicitDimension is a type of explicit content
+type ExplicitDimension uint
+
 ///////////////////////////////////////////////////////////////////////////////
 // PRIVATE STRUCTS
 
@@ -107,8 +279,23 @@ const (
 	ANNOTATION_LABEL            AnnotationType = 1 << iota
 	ANNOTATION_SHOT_CHANGE      AnnotationType = 1 << iota
 	ANNOTATION_EXPLICIT_CONTENT AnnotationType = 1 << iota
+	ANNOTATION_OBJECT_TRACKING  AnnotationType = 1 << iota
+	ANNOTATION_TEXT_DETECTION   AnnotationType = 1 << iota
+	ANNOTATION_SPEECH           AnnotationType = 1 << iota
+	ANNOTATION_PERSON_DETECTION AnnotationType = 1 << iota
+	ANNOTATION_FACE_DETECTION   AnnotationType = 1 << iota
+	ANNOTATION_LOGO_RECOGNITION AnnotationType = 1 << iota
+	ANNOTATION_MAX              AnnotationType = 1 << iota
 )
 
+const (
+	EXPLICIT_ADULT ExplicitDimension = iota
+	EXPLICIT_SPOOF
+	EXPLICIT_MEDICAL
+	EXPLICIT_VIOLENT
+	EXPLICIT_RACY
+	EXPLICIT_MAX
+)
 const (
 	LIKELIHOOD_UNSPECIFIED LikelihoodType = iota
 	LIKELIHOOD_VERY_UNLIKELY
@@ -121,17 +308,22 @@ const (
 const (
 	// Duration which to fetch remote status
 	duration_CACHE_EXPIRY time.Duration = 1 * time.Minute
+	// Scheme for Google Cloud Storage URIs
+	cloud_STORAGE_SCHEME = "gs://"
 )
 
 var (
-	ErrInvalidServiceAccount = errors.New("Invalid Service Account")
-	ErrNotFound              = errors.New("Not found")
-	ErrInProgress            = errors.New("In progress")
+	ErrInvalidServiceAccount    = errors.New("Invalid Service Account")
+	ErrNotFound                 = errors.New("Not found")
+	ErrInProgress               = errors.New("In progress")
+	ErrInvalidLikelihood        = errors.New("Invalid likelihood")
+	ErrInvalidExplicitDimension = errors.New("Invalid explicit content type")
 )
 
 var (
 	likelihood_map = map[string]LikelihoodType{
 		"LIKELIHOOD_UNSPECIFIED": LIKELIHOOD_UNSPECIFIED,
+		"UNKNOWN":                LIKELIHOOD_UNSPECIFIED,
 		"VERY_UNLIKELY":          LIKELIHOOD_VERY_UNLIKELY,
 		"UNLIKELY":               LIKELIHOOD_UNLIKELY,
 		"POSSIBLE":               LIKELIHOOD_POSSIBLE,
@@ -146,45 +338,118 @@ var (
 // NewServiceFromServiceAccountJSON returns service object and error given
 // the filename to the Service Account JSON file which can be downloaded from the
 // Google Developer Console
-func NewServiceFromServiceAccountJSON(filename string, debug bool) (*Service, error) {
+func NewServiceFromServiceAccountJSON(filename string, debug bool, options ...Option) (*Service, error) {
 	bytes, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, ErrInvalidServiceAccount
 	}
-	saConfig, err := google.JWTConfigFromJSON(bytes, v1beta2.CloudPlatformScope)
+	saConfig, err := google.JWTConfigFromJSON(bytes, v1.CloudPlatformScope)
 	if err != nil {
 		return nil, ErrInvalidServiceAccount
 	}
-	client := saConfig.Client(getContext(debug))
-	if videos, err := v1beta2.New(client); err != nil {
-		return nil, err
-	} else if ops, err := v1.New(client); err != nil {
+	return NewServiceWithClient(saConfig.Client(getContext(debug)), options...)
+}
+
+// NewServiceWithClient returns a service object given an authenticated
+// client, and options such as the API version
+func NewServiceWithClient(client *http.Client, options ...Option) (*Service, error) {
+	this := &Service{
+		client:   client,
+		version:  VERSION_V1BETA2,
+		endpoint: api_ENDPOINT,
+		status:   make(map[string]*Status),
+	}
+	for _, option := range options {
+		if err := option(this); err != nil {
+			return nil, err
+		}
+	}
+	if backend, err := newBackend(client, this.version, this.endpoint); err != nil {
 		return nil, err
 	} else {
-		return &Service{videos, ops, make(map[string]*Status)}, nil
+		this.backend = backend
 	}
+	return this, nil
 }
 
 // Annotate will kick of the annotation process, and provide a unique ID on return
 // for the annotation process. You can then use "OperationResponse" to return the
-// result of the annotation when done.
+// result of the annotation when done. The uri is either a Google Cloud Storage
+// URI or the path to a local file, which is sent with the request.
 func (this *Service) Annotate(uri string, flags AnnotationType) (string, error) {
-	call := this.videos.Videos.Annotate(&v1beta2.GoogleCloudVideointelligenceV1beta2AnnotateVideoRequest{
-		Features: annotateFlagArray(flags),
-		InputUri: uri,
-	})
-	if response, err := call.Do(); err != nil {
+	return this.AnnotateWithConfig(uri, flags, nil)
+}
+
+// AnnotateWithConfig will kick of the annotation process with detection
+// parameters, which can be nil. When a cache is set and contains annotations
+// for the same input, features and parameters, the annotations are returned
+// without a request being made. When a budget is set, the request is refused
+// with *ErrBudgetExceeded if its estimated cost would exceed the budget
+func (this *Service) AnnotateWithConfig(uri string, flags AnnotationType, config *Config) (string, error) {
+	if err := ValidateInput(uri); err != nil {
+		return "", err
+	}
+
+	// Return cached annotations. Inputs which cannot be cached are annotated
+	// without the cache
+	key := ""
+	if this.cache != nil {
+		var err error
+		if key, err = this.Fingerprint(uri, flags, config); err == ErrNotCacheable {
+			key = ""
+		} else if err != nil {
+			return "", err
+		} else if annotations, err := this.cache.Get(key); err == nil {
+			return this.setCachedStatus(key, uri, flags, annotations), nil
+		} else if err != ErrNotFound {
+			return "", err
+		}
+	}
+
+	// Estimate the cost and check it against the budget. Without a budget the
+	// cost of inputs with unknown duration is estimated once they complete
+	var estimate *VideoEstimate
+	if this.ledger != nil || this.budget != nil {
+		if cost, err := this.estimateCost(uri, flags, config, 0); err == nil {
+			estimate = cost
+		} else if this.budget != nil {
+			return "", err
+		}
+		if estimate != nil {
+			if err := this.checkBudget(uri, estimate.Total); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	request := &annotateRequest{uri: uri, flags: flags, config: config}
+	if IsCloudStorageUri(uri) == false {
+		if content, err := ioutil.ReadFile(uri); err != nil {
+			return "", err
+		} else {
+			request.content = content
+		}
+	}
+	if name, err := this.backend.Annotate(request); err != nil {
 		return "", err
 	} else {
+		if estimate != nil {
+			this.spent += estimate.Total
+			this.pending += estimate.Total
+		}
 		// Append the operation name into the list of current operations
-		this.status[response.Name] = &Status{
-			Name:        response.Name,
+		this.status[name] = &Status{
+			Name:        name,
 			Uri:         uri,
 			Type:        annotateTypeArray(flags),
 			Progress:    make(map[AnnotationType]*Progress, 3),
 			Annotations: new(Annotations),
+			key:         key,
+			flags:       flags,
+			config:      config,
+			estimate:    estimate,
 		}
-		return response.Name, nil
+		return name, nil
 	}
 }
 
@@ -193,69 +458,194 @@ func (this *Service) Status(name string) (*Status, error) {
 	if exists == false {
 		return nil, ErrNotFound
 	}
-	call := this.ops.Operations.Get(name)
-	if response, err := call.Do(); err != nil {
+	if status.err != nil {
+		return nil, status.err
+	} else if status.Done {
+		// Completed operations do not change
+		return status, nil
+	}
+	if op, err := this.backend.Operation(name, status.Uri, status.Type); err == errNotModified {
+		// Nothing has changed since the last poll
+		status.Updated = time.Now()
+		return status, nil
+	} else if _, failed := err.(*OperationError); failed {
+		// The operation has failed, so isn't polled again and there are no
+		// annotations to cache or record
+		if status.estimate != nil {
+			this.pending -= status.estimate.Total
+		}
+		status.err = err
+		status.Done = true
+		status.Updated = time.Now()
+		return nil, err
+	} else if err != nil {
 		return nil, err
 	} else {
-		var progress v1beta2.GoogleCloudVideointelligenceV1AnnotateVideoProgress
-		if err := json.Unmarshal(response.Metadata, &progress); err != nil {
-			return nil, err
+		for annotationType, progress := range op.progress {
+			status.SetProgress(annotationType, progress)
 		}
-		// decode the status codes
-		for i, statusDetail := range progress.AnnotationProgress {
-			annotationType := status.Type[i]
-			startTime, _ := time.Parse(time.RFC3339Nano, statusDetail.StartTime)
-			updateTime, _ := time.Parse(time.RFC3339Nano, statusDetail.UpdateTime)
-			done := (statusDetail.ProgressPercent == 100)
-			status.Progress[annotationType] = &Progress{
-				done,
-				statusDetail.ProgressPercent,
-				startTime,
-				updateTime,
-			}
+		if op.annotations != nil {
+			status.Annotations = op.annotations
 		}
-		// decode the response
-		if response.Response != nil {
-			var annotations v1beta2.GoogleCloudVideointelligenceV1AnnotateVideoResponse
-			if err := json.Unmarshal(response.Response, &annotations); err != nil {
+
+		// store completed annotations in the cache
+		if op.annotations != nil && this.cache != nil && status.key != "" {
+			if err := this.cache.Set(status.key, status.Uri, status.Annotations); err != nil {
 				return nil, err
 			}
-			for _, annotationDetail := range annotations.AnnotationResults {
-				if annotationDetail.FrameLabelAnnotations != nil {
-					fmt.Println("TODO: annotationDetail.FrameLabelAnnotations")
-				}
-				if annotationDetail.ShotLabelAnnotations != nil {
-					this.setShotLabelAnnotations(status, annotationDetail.ShotLabelAnnotations)
-				}
-				if annotationDetail.ShotAnnotations != nil {
-					this.setShotAnnotations(status, annotationDetail.ShotAnnotations)
-				}
-				if annotationDetail.SegmentLabelAnnotations != nil {
-					this.setSegmentLabelAnnotations(status, annotationDetail.SegmentLabelAnnotations)
-				}
-				if annotationDetail.ExplicitAnnotation != nil {
-					this.setExplicitAnnotation(status, annotationDetail.ExplicitAnnotation)
-				}
+		}
+
+		// record the usage of completed operations
+		if op.done && status.estimate != nil {
+			this.pending -= status.estimate.Total
+		}
+		if op.annotations != nil && this.ledger != nil {
+			if err := this.recordUsage(status); err != nil {
+				return nil, err
 			}
 		}
 
 		// set the done flag and updated flag
-		status.Done = response.Done
+		status.Done = op.done
 		status.Updated = time.Now()
 		return status, nil
 	}
 }
 
-///////////////////////////////////////////////////////////////////////////////
-// STATUS METHODS
+// Get returns the likelihood for a type of explicit content
+func (this *ExplicitContentAnnotation) Get(dimension ExplicitDimension) LikelihoodType {
+	switch dimension {
+	case EXPLICIT_ADULT:
+		return this.Likelihood
+	case EXPLICIT_SPOOF:
+		return this.Spoof
+	case EXPLICIT_MEDICAL:
+		return this.Medical
+	case EXPLICIT_VIOLENT:
+		return this.Violent
+	case EXPLICIT_RACY:
+		return this.Racy
+	default:
+		return LIKELIHOOD_UNSPECIFIED
+	}
+}
 
-// PercentComplete returns the percentage completion of the operation
-func (this *Status) PercentComplete() float64 {
-	var percent float64
-	for _, value := range this.Progress {
-		percent += float64(value.Percent)
+// ParseExplicitDimension returns a type of explicit content from a name
+// such as adult or violent, ignoring case
+func ParseExplicitDimension(value string) (ExplicitDimension, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	for dimension := EXPLICIT_ADULT; dimension < EXPLICIT_MAX; dimension++ {
+		if value == dimension.String() || "EXPLICIT_"+value == dimension.String() {
+			return dimension, nil
+		}
 	}
-	return percent / float64(len(this.Progress))
+	return EXPLICIT_ADULT, ErrInvalidExplicitDimension
+}
+
+// ParseLikelihood returns a likelihood from a name such as LIKELY or
+// LIKELIHOOD_LIKELY, ignoring case
+func ParseLikelihood(value string) (LikelihoodType, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if likelihood, exists := likelihood_map[value]; exists {
+		return likelihood, nil
+	} else if likelihood, exists := likelihood_map[strings.TrimPrefix(value, "LIKELIHOOD_")]; exists {
+		return likelihood, nil
+	}
+	return LIKELIHOOD_UNSPECIFIED, ErrInvalidLikelihood
+}
+
+// Wait polls an operation at an interval until it has completed
+func (this *Service) Wait(name string, interval time.Duration) (*Status, error) {
+	return WaitForStatus(func() (*Status, error) {
+		return this.Status(name)
+	}, interval)
+}
+
+// Cancel requests that an operation is cancelled, and stops tracking it.
+// Operations for cached annotations are not sent to the API
+func (this *Service) Cancel(name string) error {
+	status, exists := this.status[name]
+	if exists == false {
+		return ErrNotFound
+	}
+	if status.Cached == false && status.Done == false {
+		if err := this.backend.Cancel(name); err != nil {
+			return err
+		}
+		if status.estimate != nil {
+			this.pending -= status.estimate.Total
+		}
+	}
+	delete(this.status, name)
+	return nil
+}
+
+// IsCloudStorageUri returns true if the uri refers to Google Cloud Storage
+// rather than a local file
+func IsCloudStorageUri(uri string) bool {
+	return strings.HasPrefix(uri, cloud_STORAGE_SCHEME)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// ANNOTATIONS METHODS
+
+// Duration returns the latest end offset over all the annotations, which is
+// the best estimate of the video duration
+func (this *Annotations) Duration() time.Duration {
+	var duration time.Duration
+	for _, shot := range this.Shots {
+		if shot.EndOffset > duration {
+			duration = shot.EndOffset
+		}
+	}
+	for _, labels := range [][]*EntityAnnotation{this.ShotLabels, this.SegmentLabels, this.FrameLabels} {
+		for _, label := range labels {
+			for _, segment := range label.Segments {
+				if segment.EndOffset > duration {
+					duration = segment.EndOffset
+				}
+			}
+		}
+	}
+	for _, annotation := range this.ExplicitContent {
+		if annotation.Offset > duration {
+			duration = annotation.Offset
+		}
+	}
+	for _, track := range this.ObjectTracks {
+		if track.EndOffset > duration {
+			duration = track.EndOffset
+		}
+	}
+	for _, text := range this.Text {
+		for _, segment := range text.Segments {
+			if segment.EndOffset > duration {
+				duration = segment.EndOffset
+			}
+		}
+	}
+	for _, word := range this.Words() {
+		if word.EndOffset > duration {
+			duration = word.EndOffset
+		}
+	}
+	for _, track := range this.Tracks() {
+		if track.EndOffset > duration {
+			duration = track.EndOffset
+		}
+	}
+	// Extension features are included through their rendered rows
+	for _, feature := range features {
+		if feature.Type&extensionTypes() == 0 || feature.Render == nil {
+			continue
+		}
+		for _, row := range feature.Render(this) {
+			if row.End > duration {
+				duration = row.End
+			}
+		}
+	}
+	return duration
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -272,34 +662,48 @@ func getContext(debug bool) context.Context {
 	return ctx
 }
 
-// Returns array of annotation flags as a string
+// annotateFlagArray returns the names of the registered features for the
+// annotation flags, in order of annotation type
 func annotateFlagArray(flags AnnotationType) []string {
 	flagArray := make([]string, 0, 3)
-	if flags&ANNOTATION_LABEL != ANNOTATION_NONE {
-		flagArray = append(flagArray, "LABEL_DETECTION")
-	}
-	if flags&ANNOTATION_SHOT_CHANGE != ANNOTATION_NONE {
-		flagArray = append(flagArray, "SHOT_CHANGE_DETECTION")
-	}
-	if flags&ANNOTATION_EXPLICIT_CONTENT != ANNOTATION_NONE {
-		flagArray = append(flagArray, "EXPLICIT_CONTENT_DETECTION")
+	for _, annotationType := range annotateTypeArray(flags) {
+		flagArray = append(flagArray, FeatureFor(annotationType).Name)
 	}
 	return flagArray
 }
 
-// Returns array of annotation flags as a string
+// annotateTypeArray returns the registered annotation types for the
+// annotation flags, in order of annotation type so that requests and cache
+// keys don't depend on the order features are registered
 func annotateTypeArray(flags AnnotationType) []AnnotationType {
 	typeArray := make([]AnnotationType, 0, 3)
-	if flags&ANNOTATION_LABEL != ANNOTATION_NONE {
-		typeArray = append(typeArray, ANNOTATION_LABEL)
+	for annotationType := AnnotationType(1); annotationType != ANNOTATION_NONE && annotationType <= flags; annotationType <<= 1 {
+		if flags&annotationType != ANNOTATION_NONE && FeatureFor(annotationType) != nil {
+			typeArray = append(typeArray, annotationType)
+		}
 	}
-	if flags&ANNOTATION_SHOT_CHANGE != ANNOTATION_NONE {
-		typeArray = append(typeArray, ANNOTATION_SHOT_CHANGE)
+	return typeArray
+}
+
+// setCachedStatus adds a completed operation for cached annotations, and
+// returns the operation name
+func (this *Service) setCachedStatus(key, uri string, flags AnnotationType, annotations *Annotations) string {
+	status := &Status{
+		Name:        cache_OPERATION_PREFIX + key,
+		Uri:         uri,
+		Done:        true,
+		Type:        annotateTypeArray(flags),
+		Progress:    make(map[AnnotationType]*Progress, 3),
+		Updated:     time.Now(),
+		Annotations: annotations,
+		Cached:      true,
+		key:         key,
 	}
-	if flags&ANNOTATION_EXPLICIT_CONTENT != ANNOTATION_NONE {
-		typeArray = append(typeArray, ANNOTATION_EXPLICIT_CONTENT)
+	for _, annotationType := range status.Type {
+		status.Progress[annotationType] = &Progress{Done: true, Percent: 100, StartTime: status.Updated, UpdateTime: status.Updated}
 	}
-	return typeArray
+	this.status[status.Name] = status
+	return status.Name
 }
 
 // getCachedStatus returns a status object, or a refresh the status object if
@@ -341,107 +745,14 @@ func (this *Service) getCachedProgress(name string, annotationType AnnotationTyp
 	}
 }
 
-// setExplicitAnnotation interprets the explicit annotations
-func (this *Service) setExplicitAnnotation(status *Status, annotations *v1beta2.GoogleCloudVideointelligenceV1ExplicitContentAnnotation) error {
-	status.Annotations.ExplicitContent = make([]*ExplicitContentAnnotation, len(annotations.Frames))
-	for i, annotation := range annotations.Frames {
-		offset, err := time.ParseDuration(annotation.TimeOffset)
-		if err != nil {
-			return err
-		}
-		likelihood, exists := likelihood_map[annotation.PornographyLikelihood]
-		if exists == false {
-			likelihood = LIKELIHOOD_UNSPECIFIED
-		}
-		status.Annotations.ExplicitContent[i] = &ExplicitContentAnnotation{
-			Offset:     offset,
-			Likelihood: likelihood,
-		}
-	}
-	return nil
-}
-
-func (this *Service) setEntityAnnotations(annotations []*v1beta2.GoogleCloudVideointelligenceV1LabelAnnotation) ([]*EntityAnnotation, error) {
-	entityAnnotations := make([]*EntityAnnotation, len(annotations))
-	for i, annotation := range annotations {
-		segments := make([]*Segment, len(annotation.Segments))
-		for j, segment := range annotation.Segments {
-			startOffset, err1 := time.ParseDuration(segment.Segment.StartTimeOffset)
-			if err1 != nil {
-				return nil, err1
-			}
-			endOffset, err2 := time.ParseDuration(segment.Segment.EndTimeOffset)
-			if err2 != nil {
-				return nil, err2
-			}
-			segments[j] = &Segment{
-				StartOffset: startOffset,
-				EndOffset:   endOffset,
-				Confidence:  segment.Confidence,
-			}
-		}
-		categories := make([]*Entity, len(annotation.CategoryEntities))
-		for j, category := range annotation.CategoryEntities {
-			categories[j] = &Entity{category.EntityId, category.Description, category.LanguageCode}
-		}
-		entityAnnotations[i] = &EntityAnnotation{
-			Entity:     &Entity{annotation.Entity.EntityId, annotation.Entity.Description, annotation.Entity.LanguageCode},
-			Segments:   segments,
-			Categories: categories,
-		}
-	}
-	return entityAnnotations, nil
-}
-
-func (this *Service) setSegmentLabelAnnotations(status *Status, annotations []*v1beta2.GoogleCloudVideointelligenceV1LabelAnnotation) error {
-	var err error
-	if status.Annotations.SegmentLabels, err = this.setEntityAnnotations(annotations); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (this *Service) setShotLabelAnnotations(status *Status, annotations []*v1beta2.GoogleCloudVideointelligenceV1LabelAnnotation) error {
-	var err error
-	if status.Annotations.ShotLabels, err = this.setEntityAnnotations(annotations); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (this *Service) setShotAnnotations(status *Status, annotations []*v1beta2.GoogleCloudVideointelligenceV1VideoSegment) error {
-	status.Annotations.Shots = make([]*ShotAnnotation, len(annotations))
-	for i, annotation := range annotations {
-		startOffset, err1 := time.ParseDuration(annotation.StartTimeOffset)
-		if err1 != nil {
-			return err1
-		}
-		endOffset, err2 := time.ParseDuration(annotation.EndTimeOffset)
-		if err2 != nil {
-			return err2
-		}
-		status.Annotations.Shots[i] = &ShotAnnotation{
-			StartOffset: startOffset,
-			EndOffset:   endOffset,
-		}
-	}
-	return nil
-}
-
 ///////////////////////////////////////////////////////////////////////////////
 // STRINGIFY
 
 func (t AnnotationType) String() string {
-	switch t {
-	case ANNOTATION_LABEL:
-		return "ANNOTATION_LABEL"
-	case ANNOTATION_SHOT_CHANGE:
-		return "ANNOTATION_SHOT_CHANGE"
-	case ANNOTATION_EXPLICIT_CONTENT:
-		return "ANNOTATION_EXPLICIT_CONTENT"
-	default:
-		return "ANNOTATION_NONE"
+	if feature := FeatureFor(t); feature != nil {
+		return "ANNOTATION_" + feature.Name
 	}
+	return "ANNOTATION_NONE"
 }
 
 func (l LikelihoodType) String() string {
@@ -461,9 +772,26 @@ func (l LikelihoodType) String() string {
 	}
 }
 
+func (d ExplicitDimension) String() string {
+	switch d {
+	case EXPLICIT_ADULT:
+		return "EXPLICIT_ADULT"
+	case EXPLICIT_SPOOF:
+		return "EXPLICIT_SPOOF"
+	case EXPLICIT_MEDICAL:
+		return "EXPLICIT_MEDICAL"
+	case EXPLICIT_VIOLENT:
+		return "EXPLICIT_VIOLENT"
+	case EXPLICIT_RACY:
+		return "EXPLICIT_RACY"
+	default:
+		return "[?? Invalid ExplicitDimension value]"
+	}
+}
+
 func (s Status) String() string {
 	progress := make([]string, 0, 3)
-	for _, annotationType := range []AnnotationType{ANNOTATION_LABEL, ANNOTATION_EXPLICIT_CONTENT, ANNOTATION_SHOT_CHANGE} {
+	for _, annotationType := range annotateTypeArray(registeredTypes()) {
 		annotationProgress, exists := s.Progress[annotationType]
 		if exists {
 			progress = append(progress, fmt.Sprintf("%v=%v", annotationType, annotationProgress))
@@ -481,13 +809,43 @@ func (a *ShotAnnotation) String() string {
 }
 
 func (a *ExplicitContentAnnotation) String() string {
-	return fmt.Sprintf("ExplicitContentAnnotation{ offset=%v likelihood=%v }", a.Offset, a.Likelihood)
+	str := fmt.Sprintf("ExplicitContentAnnotation{ offset=%v likelihood=%v", a.Offset, a.Likelihood)
+	for dimension := EXPLICIT_SPOOF; dimension < EXPLICIT_MAX; dimension++ {
+		if likelihood := a.Get(dimension); likelihood != LIKELIHOOD_UNSPECIFIED {
+			str += fmt.Sprintf(" %v=%v", dimension, likelihood)
+		}
+	}
+	return str + " }"
 }
 
 func (a *EntityAnnotation) String() string {
 	return fmt.Sprintf("EntityAnnotation{ entity=%v categories=%v segments=%v }", a.Entity, a.Categories, a.Segments)
 }
 
+func (t *ObjectTrack) String() string {
+	return fmt.Sprintf("ObjectTrack{ entity=%v confidence=%v start=%v end=%v frames=%v }", t.Entity, t.Confidence, t.StartOffset, t.EndOffset, len(t.Frames))
+}
+
+func (t *TextAnnotation) String() string {
+	return fmt.Sprintf("TextAnnotation{ text=%q segments=%v }", t.Text, len(t.Segments))
+}
+
+func (t *SpeechTranscription) String() string {
+	return fmt.Sprintf("SpeechTranscription{ language=%v alternatives=%v }", t.LanguageCode, len(t.Alternatives))
+}
+
+func (w *Word) String() string {
+	return fmt.Sprintf("Word{ word=%q start=%v end=%v speaker=%v }", w.Word, w.StartOffset, w.EndOffset, w.Speaker)
+}
+
+func (t *Track) String() string {
+	return fmt.Sprintf("Track{ start=%v end=%v confidence=%v attributes=%v frames=%v }", t.StartOffset, t.EndOffset, t.Confidence, len(t.Attributes), len(t.Frames))
+}
+
+func (a *Attribute) String() string {
+	return fmt.Sprintf("Attribute{ name=%v value=%v confidence=%v }", a.Name, a.Value, a.Confidence)
+}
+
 func (s *Segment) String() string {
 	return fmt.Sprintf("Segment{ start=%v end=%v }", s.StartOffset, s.EndOffset)
 }
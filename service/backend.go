@@ -0,0 +1,297 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/djthorpe/VideoIntelligence/videointelligence/v1"
+	googleapi "google.golang.org/api/googleapi"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Version is the version of the Video Intelligence API used for requests
+type Version uint
+
+// Option sets a parameter for a new service
+type Option func(*Service) error
+
+// OperationError is returned when an operation or the annotation of a video
+// has failed, with the status code and message from the API
+type OperationError struct {
+	Name    string
+	Code    int64
+	Message string
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// backend translates requests and responses for one version of the API to
+// and from the version-neutral model
+type backend interface {
+	// Annotate submits a request and returns the operation name
+	Annotate(request *annotateRequest) (string, error)
+
+	// Operation returns the progress of an operation for the input URI and
+	// annotation types which were requested, and the annotations when
+	// complete. Returns errNotModified if the operation hasn't changed, or
+	// an OperationError if it has failed
+	Operation(name, uri string, types []AnnotationType) (*operation, error)
+
+	// Cancel requests that an operation is cancelled
+	Cancel(name string) error
+}
+
+// annotateRequest is a version-neutral annotation request, where content
+// is the local file content or nil for Cloud Storage URIs
+type annotateRequest struct {
+	uri     string
+	content []byte
+	flags   AnnotationType
+	config  *Config
+}
+
+// operations polls and cancels operations for all API versions. Until an
+// operation is done only its status is requested, with the entity tag of
+// the last response so that unchanged operations aren't sent again
+type operations struct {
+	service *v1.Service
+	etags   map[string]string
+}
+
+// operation is the version-neutral state of an operation. The annotations
+// are nil until the operation has completed successfully
+type operation struct {
+	done        bool
+	progress    map[AnnotationType]*Progress
+	annotations *Annotations
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	VERSION_V1BETA1 Version = iota
+	VERSION_V1BETA2
+	VERSION_V1
+)
+
+const (
+	// Default API endpoint
+	api_ENDPOINT = "https://videointelligence.googleapis.com/"
+)
+
+const (
+	// Fields requested when polling an operation which isn't done
+	operation_STATUS_FIELDS = "done,metadata,error"
+)
+
+var (
+	ErrInvalidVersion = errors.New("Invalid API version")
+	ErrNotSupported   = errors.New("Not supported by this API version")
+)
+
+var (
+	// An operation hasn't changed since it was last polled
+	errNotModified = errors.New("Not modified")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// WithVersion sets the version of the API, which is v1beta2 by default
+func WithVersion(version Version) Option {
+	return func(this *Service) error {
+		if version > VERSION_V1 {
+			return ErrInvalidVersion
+		}
+		this.version = version
+		return nil
+	}
+}
+
+// WithEndpoint sets the API endpoint, for example for a regional endpoint
+func WithEndpoint(endpoint string) Option {
+	return func(this *Service) error {
+		if strings.HasSuffix(endpoint, "/") == false {
+			endpoint += "/"
+		}
+		this.endpoint = endpoint
+		return nil
+	}
+}
+
+// ParseVersion returns an API version from a name such as v1
+func ParseVersion(value string) (Version, error) {
+	for version := VERSION_V1BETA1; version <= VERSION_V1; version++ {
+		if strings.EqualFold(value, version.String()) {
+			return version, nil
+		}
+	}
+	return VERSION_V1BETA2, ErrInvalidVersion
+}
+
+// Version returns the version of the API used for requests
+func (this *Service) Version() Version {
+	return this.version
+}
+
+// CheckFeatures returns an error if any of the annotation types are not
+// supported by an API version. Registered features which are not built in
+// are supported by all versions
+func CheckFeatures(version Version, flags AnnotationType) error {
+	supported := extensionTypes()
+	switch version {
+	case VERSION_V1:
+		supported |= registeredTypes()
+	case VERSION_V1BETA2:
+		supported |= v1beta2_FEATURES
+	case VERSION_V1BETA1:
+		supported |= v1beta1_FEATURES
+	default:
+		return ErrInvalidVersion
+	}
+	if unsupported := annotateTypeArray(flags &^ supported); len(unsupported) > 0 {
+		return fmt.Errorf("%v: %v: %v", unsupported[0], ErrNotSupported, version)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// newBackend returns the backend for an API version. All versions use the
+// v1 operations service to poll operations, since long-running operations
+// are the same for each version
+func newBackend(client *http.Client, version Version, endpoint string) (backend, error) {
+	service, err := v1.New(client)
+	if err != nil {
+		return nil, err
+	}
+	service.BasePath = endpoint
+	ops := &operations{service, make(map[string]string)}
+	switch version {
+	case VERSION_V1:
+		return newV1Backend(client, endpoint, ops)
+	case VERSION_V1BETA2:
+		return newV1beta2Backend(client, endpoint, ops)
+	case VERSION_V1BETA1:
+		return newV1beta1Backend(client, endpoint, ops)
+	default:
+		return nil, ErrInvalidVersion
+	}
+}
+
+// Get returns the status of an operation, or errNotModified if it hasn't
+// changed since the last poll. When the operation is done, the full
+// operation including the response is returned, which is only requested
+// once, or an OperationError if the operation has failed
+func (this *operations) Get(name string) (*v1.GoogleLongrunningOperation, error) {
+	call := this.service.Operations.Get(name).Fields(operation_STATUS_FIELDS)
+	if etag, exists := this.etags[name]; exists {
+		call.IfNoneMatch(etag)
+	}
+	response, err := call.Do()
+	if googleapi.IsNotModified(err) {
+		return nil, errNotModified
+	} else if err != nil {
+		return nil, err
+	} else if err := newOperationError(name, response.Error); err != nil {
+		delete(this.etags, name)
+		return nil, err
+	} else if response.Done == false {
+		if etag := response.Header.Get("Etag"); etag != "" {
+			this.etags[name] = etag
+		}
+		return response, nil
+	}
+
+	// Fetch the response now the operation is done
+	delete(this.etags, name)
+	return this.service.Operations.Get(name).Do()
+}
+
+// Cancel requests that an operation is cancelled
+func (this *operations) Cancel(name string) error {
+	delete(this.etags, name)
+	_, err := this.service.Operations.Cancel(name, &v1.GoogleLongrunningCancelOperationRequest{}).Do()
+	return err
+}
+
+// postAnnotate sends an annotation request for API versions or features
+// without a generated client, and returns the operation name
+func postAnnotate(client *http.Client, url string, body interface{}) (string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	response, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if err := googleapi.CheckResponse(response); err != nil {
+		return "", err
+	}
+	op := new(v1.GoogleLongrunningOperation)
+	if err := json.NewDecoder(response.Body).Decode(op); err != nil {
+		return "", err
+	}
+	return op.Name, nil
+}
+
+// parseOffset returns an offset such as 1.5s, where an empty value is zero
+func parseOffset(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// newOperationError returns an error for a failed operation, or nil if the
+// status is nil
+func newOperationError(name string, status *v1.GoogleRpcStatus) error {
+	if status == nil {
+		return nil
+	}
+	return &OperationError{name, status.Code, status.Message}
+}
+
+// newProgress returns progress for an annotation type from an API response
+func newProgress(percent int64, startTime, updateTime string) *Progress {
+	start, _ := time.Parse(time.RFC3339Nano, startTime)
+	update, _ := time.Parse(time.RFC3339Nano, updateTime)
+	return &Progress{
+		Done:       percent == 100,
+		Percent:    percent,
+		StartTime:  start,
+		UpdateTime: update,
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (this *OperationError) Error() string {
+	return fmt.Sprintf("Operation failed: %v: %v (code %v)", this.Name, this.Message, this.Code)
+}
+
+func (v Version) String() string {
+	switch v {
+	case VERSION_V1BETA1:
+		return "v1beta1"
+	case VERSION_V1BETA2:
+		return "v1beta2"
+	case VERSION_V1:
+		return "v1"
+	default:
+		return "[?? Invalid Version value]"
+	}
+}
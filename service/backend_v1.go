@@ -0,0 +1,240 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	v1 "github.com/djthorpe/VideoIntelligence/videointelligence/v1"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// v1Backend sends requests with hand-written types, since the generated
+// client predates some features, and polls with the generated client
+type v1Backend struct {
+	client   *http.Client
+	endpoint string
+	ops      *operations
+}
+
+type v1Request struct {
+	InputUri     string          `json:"inputUri,omitempty"`
+	InputContent string          `json:"inputContent,omitempty"`
+	Features     []string        `json:"features"`
+	VideoContext *v1VideoContext `json:"videoContext,omitempty"`
+	LocationId   string          `json:"locationId,omitempty"`
+}
+
+type v1VideoContext struct {
+	Segments                       []*v1.GoogleCloudVideointelligenceV1VideoSegment                 `json:"segments,omitempty"`
+	LabelDetectionConfig           *v1.GoogleCloudVideointelligenceV1LabelDetectionConfig           `json:"labelDetectionConfig,omitempty"`
+	ShotChangeDetectionConfig      *v1.GoogleCloudVideointelligenceV1ShotChangeDetectionConfig      `json:"shotChangeDetectionConfig,omitempty"`
+	ExplicitContentDetectionConfig *v1.GoogleCloudVideointelligenceV1ExplicitContentDetectionConfig `json:"explicitContentDetectionConfig,omitempty"`
+
+	// Fields for registered features
+	features map[string]interface{}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	v1_ANNOTATE_PATH = "v1/videos:annotate"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func newV1Backend(client *http.Client, endpoint string, ops *operations) (*v1Backend, error) {
+	return &v1Backend{client, endpoint, ops}, nil
+}
+
+func (this *v1Backend) Annotate(request *annotateRequest) (string, error) {
+	if err := CheckFeatures(VERSION_V1, request.flags); err != nil {
+		return "", err
+	}
+	body := &v1Request{
+		Features:     annotateFlagArray(request.flags),
+		VideoContext: v1VideoContextFor(request.config),
+	}
+	if context := featureContext(request.flags, request.config); len(context) > 0 {
+		if body.VideoContext == nil {
+			body.VideoContext = &v1VideoContext{}
+		}
+		body.VideoContext.features = context
+	}
+	if request.config != nil {
+		body.LocationId = request.config.LocationId
+	}
+	if request.content == nil {
+		body.InputUri = request.uri
+	} else {
+		body.InputContent = base64.StdEncoding.EncodeToString(request.content)
+	}
+	return postAnnotate(this.client, this.endpoint+v1_ANNOTATE_PATH, body)
+}
+
+func (this *v1Backend) Operation(name, uri string, types []AnnotationType) (*operation, error) {
+	response, err := this.ops.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{done: response.Done}
+
+	// Decode the progress for each annotation type
+	if progress, err := decodeProgress(response.Metadata, uri, types); err != nil {
+		return nil, err
+	} else {
+		op.progress = progress
+	}
+
+	// Decode the annotations
+	if response.Done && response.Response != nil {
+		var results v1.GoogleCloudVideointelligenceV1AnnotateVideoResponse
+		if err := json.Unmarshal(response.Response, &results); err != nil {
+			return nil, err
+		}
+		op.annotations = new(Annotations)
+		for _, result := range results.AnnotationResults {
+			if result.Error != nil {
+				return nil, newOperationError(name, result.Error)
+			} else if err := v1Annotations(op.annotations, result); err != nil {
+				return nil, err
+			}
+		}
+		// Decode the features which aren't in the generated response
+		if err := decodeFeatures(op.annotations, registeredTypes(), response.Response); err != nil {
+			return nil, err
+		}
+	}
+	return op, nil
+}
+
+func (this *v1Backend) Cancel(name string) error {
+	return this.ops.Cancel(name)
+}
+
+// v1VideoContextFor returns the video context for a request, or nil if
+// there is no configuration
+func v1VideoContextFor(config *Config) *v1VideoContext {
+	if config == nil {
+		return nil
+	}
+	context := &v1VideoContext{}
+	if config.LabelDetectionMode != LABEL_MODE_UNSPECIFIED || config.StationaryCamera || config.Model != "" {
+		context.LabelDetectionConfig = &v1.GoogleCloudVideointelligenceV1LabelDetectionConfig{
+			LabelDetectionMode: config.LabelDetectionMode.apiValue(),
+			StationaryCamera:   config.StationaryCamera,
+			Model:              config.Model,
+		}
+	}
+	if config.Model != "" {
+		context.ShotChangeDetectionConfig = &v1.GoogleCloudVideointelligenceV1ShotChangeDetectionConfig{
+			Model: config.Model,
+		}
+		context.ExplicitContentDetectionConfig = &v1.GoogleCloudVideointelligenceV1ExplicitContentDetectionConfig{
+			Model: config.Model,
+		}
+	}
+	for _, segment := range config.Segments {
+		context.Segments = append(context.Segments, &v1.GoogleCloudVideointelligenceV1VideoSegment{
+			StartTimeOffset: durationString(segment.StartOffset),
+			EndTimeOffset:   durationString(segment.EndOffset),
+		})
+	}
+	return context
+}
+
+// MarshalJSON encodes the video context with the fields for registered
+// features
+func (this *v1VideoContext) MarshalJSON() ([]byte, error) {
+	type context v1VideoContext
+	return mergeJSON((*context)(this), this.features)
+}
+
+// v1Annotations appends the annotations for a video
+func v1Annotations(annotations *Annotations, result *v1.GoogleCloudVideointelligenceV1VideoAnnotationResults) error {
+	var err error
+	for _, shot := range result.ShotAnnotations {
+		if segment, err := v1Segment(shot, 0); err != nil {
+			return err
+		} else {
+			annotations.Shots = append(annotations.Shots, &ShotAnnotation{segment.StartOffset, segment.EndOffset})
+		}
+	}
+	if annotations.ShotLabels, err = v1Labels(annotations.ShotLabels, result.ShotLabelAnnotations); err != nil {
+		return err
+	}
+	if annotations.SegmentLabels, err = v1Labels(annotations.SegmentLabels, result.SegmentLabelAnnotations); err != nil {
+		return err
+	}
+	if annotations.FrameLabels, err = v1Labels(annotations.FrameLabels, result.FrameLabelAnnotations); err != nil {
+		return err
+	}
+	if result.ExplicitAnnotation != nil {
+		for _, frame := range result.ExplicitAnnotation.Frames {
+			if offset, err := parseOffset(frame.TimeOffset); err != nil {
+				return err
+			} else {
+				annotations.ExplicitContent = append(annotations.ExplicitContent, &ExplicitContentAnnotation{
+					Offset:     offset,
+					Likelihood: likelihood_map[frame.PornographyLikelihood],
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func v1Labels(labels []*EntityAnnotation, annotations []*v1.GoogleCloudVideointelligenceV1LabelAnnotation) ([]*EntityAnnotation, error) {
+	for _, annotation := range annotations {
+		label := &EntityAnnotation{
+			Entity:     v1Entity(annotation.Entity),
+			Categories: make([]*Entity, 0, len(annotation.CategoryEntities)),
+			Segments:   make([]*Segment, 0, len(annotation.Segments)+len(annotation.Frames)),
+		}
+		for _, category := range annotation.CategoryEntities {
+			label.Categories = append(label.Categories, v1Entity(category))
+		}
+		for _, segment := range annotation.Segments {
+			if segment, err := v1Segment(segment.Segment, segment.Confidence); err != nil {
+				return nil, err
+			} else {
+				label.Segments = append(label.Segments, segment)
+			}
+		}
+		for _, frame := range annotation.Frames {
+			if offset, err := parseOffset(frame.TimeOffset); err != nil {
+				return nil, err
+			} else {
+				label.Segments = append(label.Segments, &Segment{offset, offset, frame.Confidence})
+			}
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+func v1Entity(entity *v1.GoogleCloudVideointelligenceV1Entity) *Entity {
+	if entity == nil {
+		return &Entity{}
+	}
+	return &Entity{entity.EntityId, entity.Description, entity.LanguageCode}
+}
+
+func v1Segment(segment *v1.GoogleCloudVideointelligenceV1VideoSegment, confidence float64) (*Segment, error) {
+	if segment == nil {
+		return &Segment{Confidence: confidence}, nil
+	}
+	start, err := parseOffset(segment.StartTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseOffset(segment.EndTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &Segment{start, end, confidence}, nil
+}
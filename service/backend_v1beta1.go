@@ -0,0 +1,236 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	v1 "github.com/djthorpe/VideoIntelligence/videointelligence/v1"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// v1beta1Backend sends requests to the v1beta1 API, which has no generated
+// client, and decodes the v1beta1 response types included with v1
+type v1beta1Backend struct {
+	client   *http.Client
+	endpoint string
+	ops      *operations
+}
+
+type v1beta1Request struct {
+	InputUri     string               `json:"inputUri,omitempty"`
+	InputContent string               `json:"inputContent,omitempty"`
+	Features     []string             `json:"features"`
+	VideoContext *v1beta1VideoContext `json:"videoContext,omitempty"`
+	LocationId   string               `json:"locationId,omitempty"`
+}
+
+type v1beta1VideoContext struct {
+	Segments                 []*v1beta1VideoSegment `json:"segments,omitempty"`
+	LabelDetectionMode       string                 `json:"labelDetectionMode,omitempty"`
+	StationaryCamera         bool                   `json:"stationaryCamera,omitempty"`
+	LabelDetectionModel      string                 `json:"labelDetectionModel,omitempty"`
+	ShotChangeDetectionModel string                 `json:"shotChangeDetectionModel,omitempty"`
+	SafeSearchDetectionModel string                 `json:"safeSearchDetectionModel,omitempty"`
+
+	// Fields for registered features
+	features map[string]interface{}
+}
+
+// v1beta1VideoSegment has offsets in microseconds
+type v1beta1VideoSegment struct {
+	StartTimeOffset int64 `json:"startTimeOffset,string"`
+	EndTimeOffset   int64 `json:"endTimeOffset,string"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	v1beta1_ANNOTATE_PATH = "v1beta1/videos:annotate"
+	v1beta1_FEATURES      = ANNOTATION_LABEL | ANNOTATION_SHOT_CHANGE | ANNOTATION_EXPLICIT_CONTENT
+)
+
+var (
+	// v1beta1 detects explicit content with safe search detection
+	v1beta1_feature_map = map[AnnotationType]string{
+		ANNOTATION_LABEL:            "LABEL_DETECTION",
+		ANNOTATION_SHOT_CHANGE:      "SHOT_CHANGE_DETECTION",
+		ANNOTATION_EXPLICIT_CONTENT: "SAFE_SEARCH_DETECTION",
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func newV1beta1Backend(client *http.Client, endpoint string, ops *operations) (*v1beta1Backend, error) {
+	return &v1beta1Backend{client, endpoint, ops}, nil
+}
+
+func (this *v1beta1Backend) Annotate(request *annotateRequest) (string, error) {
+	if err := CheckFeatures(VERSION_V1BETA1, request.flags); err != nil {
+		return "", err
+	}
+	body := &v1beta1Request{
+		Features:     make([]string, 0, 3),
+		VideoContext: v1beta1VideoContextFor(request.config),
+	}
+	for _, annotationType := range annotateTypeArray(request.flags) {
+		if name, exists := v1beta1_feature_map[annotationType]; exists {
+			body.Features = append(body.Features, name)
+		} else {
+			body.Features = append(body.Features, FeatureFor(annotationType).Name)
+		}
+	}
+	if context := featureContext(request.flags&extensionTypes(), request.config); len(context) > 0 {
+		if body.VideoContext == nil {
+			body.VideoContext = &v1beta1VideoContext{}
+		}
+		body.VideoContext.features = context
+	}
+	if request.config != nil {
+		body.LocationId = request.config.LocationId
+	}
+	if request.content == nil {
+		body.InputUri = request.uri
+	} else {
+		body.InputContent = base64.StdEncoding.EncodeToString(request.content)
+	}
+	return postAnnotate(this.client, this.endpoint+v1beta1_ANNOTATE_PATH, body)
+}
+
+func (this *v1beta1Backend) Operation(name, uri string, types []AnnotationType) (*operation, error) {
+	response, err := this.ops.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{done: response.Done}
+
+	// Decode the progress for each annotation type
+	if progress, err := decodeProgress(response.Metadata, uri, types); err != nil {
+		return nil, err
+	} else {
+		op.progress = progress
+	}
+
+	// Decode the annotations
+	if response.Done && response.Response != nil {
+		var results v1.GoogleCloudVideointelligenceV1beta1AnnotateVideoResponse
+		if err := json.Unmarshal(response.Response, &results); err != nil {
+			return nil, err
+		}
+		op.annotations = new(Annotations)
+		for _, result := range results.AnnotationResults {
+			if result.Error != nil {
+				return nil, newOperationError(name, result.Error)
+			}
+			v1beta1Annotations(op.annotations, result)
+		}
+		// Decode the features which aren't built in
+		if err := decodeFeatures(op.annotations, extensionTypes(), response.Response); err != nil {
+			return nil, err
+		}
+	}
+	return op, nil
+}
+
+func (this *v1beta1Backend) Cancel(name string) error {
+	return this.ops.Cancel(name)
+}
+
+// v1beta1VideoContextFor returns the video context for a request, or nil if
+// there is no configuration
+func v1beta1VideoContextFor(config *Config) *v1beta1VideoContext {
+	if config == nil {
+		return nil
+	}
+	context := &v1beta1VideoContext{
+		LabelDetectionMode:       config.LabelDetectionMode.apiValue(),
+		StationaryCamera:         config.StationaryCamera,
+		LabelDetectionModel:      config.Model,
+		ShotChangeDetectionModel: config.Model,
+		SafeSearchDetectionModel: config.Model,
+	}
+	for _, segment := range config.Segments {
+		context.Segments = append(context.Segments, &v1beta1VideoSegment{
+			StartTimeOffset: int64(segment.StartOffset / time.Microsecond),
+			EndTimeOffset:   int64(segment.EndOffset / time.Microsecond),
+		})
+	}
+	return context
+}
+
+// MarshalJSON encodes the video context with the fields for registered
+// features
+func (this *v1beta1VideoContext) MarshalJSON() ([]byte, error) {
+	type context v1beta1VideoContext
+	return mergeJSON((*context)(this), this.features)
+}
+
+// v1beta1Annotations appends the annotations for a video. Labels are
+// returned with a level for each location rather than in separate lists,
+// and video-level labels cover the whole video
+func v1beta1Annotations(annotations *Annotations, result *v1.GoogleCloudVideointelligenceV1beta1VideoAnnotationResults) {
+	for _, shot := range result.ShotAnnotations {
+		segment := v1beta1Segment(shot, 0)
+		annotations.Shots = append(annotations.Shots, &ShotAnnotation{segment.StartOffset, segment.EndOffset})
+	}
+	videoLabels := make([]*EntityAnnotation, 0)
+	for _, annotation := range result.LabelAnnotations {
+		levels := make(map[string]*EntityAnnotation, 1)
+		for _, location := range annotation.Locations {
+			label, exists := levels[location.Level]
+			if exists == false {
+				label = &EntityAnnotation{
+					Entity:     &Entity{Description: annotation.Description, LanguageCode: annotation.LanguageCode},
+					Categories: []*Entity{},
+				}
+				levels[location.Level] = label
+				switch location.Level {
+				case "SHOT_LEVEL":
+					annotations.ShotLabels = append(annotations.ShotLabels, label)
+				case "FRAME_LEVEL":
+					annotations.FrameLabels = append(annotations.FrameLabels, label)
+				case "VIDEO_LEVEL":
+					annotations.SegmentLabels = append(annotations.SegmentLabels, label)
+					videoLabels = append(videoLabels, label)
+				default:
+					annotations.SegmentLabels = append(annotations.SegmentLabels, label)
+				}
+			}
+			label.Segments = append(label.Segments, v1beta1Segment(location.Segment, location.Confidence))
+		}
+	}
+	for _, annotation := range result.SafeSearchAnnotations {
+		annotations.ExplicitContent = append(annotations.ExplicitContent, &ExplicitContentAnnotation{
+			Offset:     time.Duration(annotation.TimeOffset) * time.Microsecond,
+			Likelihood: likelihood_map[annotation.Adult],
+			Spoof:      likelihood_map[annotation.Spoof],
+			Medical:    likelihood_map[annotation.Medical],
+			Violent:    likelihood_map[annotation.Violent],
+			Racy:       likelihood_map[annotation.Racy],
+		})
+	}
+
+	// Video-level segments are [-1, -1], so set them to the whole video
+	duration := annotations.Duration()
+	for _, label := range videoLabels {
+		for _, segment := range label.Segments {
+			segment.StartOffset, segment.EndOffset = 0, duration
+		}
+	}
+}
+
+func v1beta1Segment(segment *v1.GoogleCloudVideointelligenceV1beta1VideoSegment, confidence float64) *Segment {
+	if segment == nil {
+		return &Segment{Confidence: confidence}
+	}
+	return &Segment{
+		StartOffset: time.Duration(segment.StartTimeOffset) * time.Microsecond,
+		EndOffset:   time.Duration(segment.EndTimeOffset) * time.Microsecond,
+		Confidence:  confidence,
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	v1 "github.com/djthorpe/VideoIntelligence/videointelligence/v1"
+	v1beta2 "github.com/djthorpe/VideoIntelligence/videointelligence/v1beta2"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// v1beta2Backend sends requests with the generated client, except when
+// registered features need video context fields which the generated types
+// don't have
+type v1beta2Backend struct {
+	client   *http.Client
+	endpoint string
+	videos   *v1beta2.Service
+	ops      *operations
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	v1beta2_ANNOTATE_PATH = "v1beta2/videos:annotate"
+	// Annotation types supported by v1beta2
+	v1beta2_FEATURES = ANNOTATION_LABEL | ANNOTATION_SHOT_CHANGE | ANNOTATION_EXPLICIT_CONTENT
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func newV1beta2Backend(client *http.Client, endpoint string, ops *operations) (*v1beta2Backend, error) {
+	if videos, err := v1beta2.New(client); err != nil {
+		return nil, err
+	} else {
+		videos.BasePath = endpoint
+		return &v1beta2Backend{client, endpoint, videos, ops}, nil
+	}
+}
+
+func (this *v1beta2Backend) Annotate(request *annotateRequest) (string, error) {
+	if err := CheckFeatures(VERSION_V1BETA2, request.flags); err != nil {
+		return "", err
+	}
+	body := &v1beta2.GoogleCloudVideointelligenceV1beta2AnnotateVideoRequest{
+		Features:     annotateFlagArray(request.flags),
+		VideoContext: v1beta2VideoContext(request.config),
+	}
+	if request.config != nil {
+		body.LocationId = request.config.LocationId
+	}
+	if request.content == nil {
+		body.InputUri = request.uri
+	} else {
+		body.InputContent = base64.StdEncoding.EncodeToString(request.content)
+	}
+	if context := featureContext(request.flags&extensionTypes(), request.config); len(context) > 0 {
+		return this.annotateWithContext(body, context)
+	}
+	if response, err := this.videos.Videos.Annotate(body).Do(); err != nil {
+		return "", err
+	} else {
+		return response.Name, nil
+	}
+}
+
+func (this *v1beta2Backend) Operation(name, uri string, types []AnnotationType) (*operation, error) {
+	response, err := this.ops.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{done: response.Done}
+
+	// Decode the progress for each annotation type
+	if progress, err := decodeProgress(response.Metadata, uri, types); err != nil {
+		return nil, err
+	} else {
+		op.progress = progress
+	}
+
+	// Decode the annotations
+	if response.Done && response.Response != nil {
+		var results v1beta2.GoogleCloudVideointelligenceV1beta2AnnotateVideoResponse
+		if err := json.Unmarshal(response.Response, &results); err != nil {
+			return nil, err
+		}
+		op.annotations = new(Annotations)
+		for _, result := range results.AnnotationResults {
+			if result.Error != nil {
+				return nil, newOperationError(name, &v1.GoogleRpcStatus{Code: result.Error.Code, Message: result.Error.Message})
+			} else if err := v1beta2Annotations(op.annotations, result); err != nil {
+				return nil, err
+			}
+		}
+		// Decode the features which aren't built in
+		if err := decodeFeatures(op.annotations, extensionTypes(), response.Response); err != nil {
+			return nil, err
+		}
+	}
+	return op, nil
+}
+
+func (this *v1beta2Backend) Cancel(name string) error {
+	return this.ops.Cancel(name)
+}
+
+// annotateWithContext sends a request with video context fields for
+// registered features added to the generated request
+func (this *v1beta2Backend) annotateWithContext(body *v1beta2.GoogleCloudVideointelligenceV1beta2AnnotateVideoRequest, context map[string]interface{}) (string, error) {
+	videoContext := body.VideoContext
+	if videoContext == nil {
+		videoContext = &v1beta2.GoogleCloudVideointelligenceV1beta2VideoContext{}
+	}
+	data, err := mergeJSON(videoContext, context)
+	if err != nil {
+		return "", err
+	}
+	body.VideoContext = nil
+	request, err := mergeJSON(body, map[string]interface{}{"videoContext": json.RawMessage(data)})
+	if err != nil {
+		return "", err
+	}
+	return postAnnotate(this.client, this.endpoint+v1beta2_ANNOTATE_PATH, json.RawMessage(request))
+}
+
+// v1beta2VideoContext returns the video context for a request, or nil if
+// there is no configuration
+func v1beta2VideoContext(config *Config) *v1beta2.GoogleCloudVideointelligenceV1beta2VideoContext {
+	if config == nil {
+		return nil
+	}
+	context := &v1beta2.GoogleCloudVideointelligenceV1beta2VideoContext{}
+	if config.LabelDetectionMode != LABEL_MODE_UNSPECIFIED || config.StationaryCamera || config.Model != "" {
+		context.LabelDetectionConfig = &v1beta2.GoogleCloudVideointelligenceV1beta2LabelDetectionConfig{
+			LabelDetectionMode: config.LabelDetectionMode.apiValue(),
+			StationaryCamera:   config.StationaryCamera,
+			Model:              config.Model,
+		}
+	}
+	if config.Model != "" {
+		context.ShotChangeDetectionConfig = &v1beta2.GoogleCloudVideointelligenceV1beta2ShotChangeDetectionConfig{
+			Model: config.Model,
+		}
+		context.ExplicitContentDetectionConfig = &v1beta2.GoogleCloudVideointelligenceV1beta2ExplicitContentDetectionConfig{
+			Model: config.Model,
+		}
+	}
+	for _, segment := range config.Segments {
+		context.Segments = append(context.Segments, &v1beta2.GoogleCloudVideointelligenceV1beta2VideoSegment{
+			StartTimeOffset: durationString(segment.StartOffset),
+			EndTimeOffset:   durationString(segment.EndOffset),
+		})
+	}
+	return context
+}
+
+// v1beta2Annotations appends the annotations for a video
+func v1beta2Annotations(annotations *Annotations, result *v1beta2.GoogleCloudVideointelligenceV1beta2VideoAnnotationResults) error {
+	var err error
+	for _, shot := range result.ShotAnnotations {
+		if segment, err := v1beta2Segment(shot, 0); err != nil {
+			return err
+		} else {
+			annotations.Shots = append(annotations.Shots, &ShotAnnotation{segment.StartOffset, segment.EndOffset})
+		}
+	}
+	if annotations.ShotLabels, err = v1beta2Labels(annotations.ShotLabels, result.ShotLabelAnnotations); err != nil {
+		return err
+	}
+	if annotations.SegmentLabels, err = v1beta2Labels(annotations.SegmentLabels, result.SegmentLabelAnnotations); err != nil {
+		return err
+	}
+	if annotations.FrameLabels, err = v1beta2Labels(annotations.FrameLabels, result.FrameLabelAnnotations); err != nil {
+		return err
+	}
+	if result.ExplicitAnnotation != nil {
+		for _, frame := range result.ExplicitAnnotation.Frames {
+			if offset, err := parseOffset(frame.TimeOffset); err != nil {
+				return err
+			} else {
+				annotations.ExplicitContent = append(annotations.ExplicitContent, &ExplicitContentAnnotation{
+					Offset:     offset,
+					Likelihood: likelihood_map[frame.PornographyLikelihood],
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func v1beta2Labels(labels []*EntityAnnotation, annotations []*v1beta2.GoogleCloudVideointelligenceV1beta2LabelAnnotation) ([]*EntityAnnotation, error) {
+	for _, annotation := range annotations {
+		label := &EntityAnnotation{
+			Entity:     v1beta2Entity(annotation.Entity),
+			Categories: make([]*Entity, 0, len(annotation.CategoryEntities)),
+			Segments:   make([]*Segment, 0, len(annotation.Segments)+len(annotation.Frames)),
+		}
+		for _, category := range annotation.CategoryEntities {
+			label.Categories = append(label.Categories, v1beta2Entity(category))
+		}
+		for _, segment := range annotation.Segments {
+			if segment, err := v1beta2Segment(segment.Segment, segment.Confidence); err != nil {
+				return nil, err
+			} else {
+				label.Segments = append(label.Segments, segment)
+			}
+		}
+		for _, frame := range annotation.Frames {
+			if offset, err := parseOffset(frame.TimeOffset); err != nil {
+				return nil, err
+			} else {
+				label.Segments = append(label.Segments, &Segment{offset, offset, frame.Confidence})
+			}
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+func v1beta2Entity(entity *v1beta2.GoogleCloudVideointelligenceV1beta2Entity) *Entity {
+	if entity == nil {
+		return &Entity{}
+	}
+	return &Entity{entity.EntityId, entity.Description, entity.LanguageCode}
+}
+
+func v1beta2Segment(segment *v1beta2.GoogleCloudVideointelligenceV1beta2VideoSegment, confidence float64) (*Segment, error) {
+	if segment == nil {
+		return &Segment{Confidence: confidence}, nil
+	}
+	start, err := parseOffset(segment.StartTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseOffset(segment.EndTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &Segment{start, end, confidence}, nil
+}
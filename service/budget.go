@@ -0,0 +1,83 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// BudgetPolicy defines the maximum estimated spend per day, per month and
+// for the lifetime of the service. A zero value means no limit. Daily and
+// monthly limits include the spend recorded in the ledger
+type BudgetPolicy struct {
+	Daily   float64
+	Monthly float64
+	PerRun  float64
+}
+
+// ErrBudgetExceeded is returned when a submission would exceed a budget
+type ErrBudgetExceeded struct {
+	Uri    string
+	Period string
+	Limit  float64
+	Spent  float64
+	Cost   float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// SetBudget sets the budget policy which is checked before each submission,
+// or nil to remove it. Inputs with an unknown duration are refused when a
+// budget is set
+func (this *Service) SetBudget(policy *BudgetPolicy) {
+	this.budget = policy
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// checkBudget returns *ErrBudgetExceeded if the cost would exceed any limit
+func (this *Service) checkBudget(uri string, cost float64) error {
+	if this.budget == nil {
+		return nil
+	}
+	if this.budget.PerRun > 0 && this.spent+cost > this.budget.PerRun {
+		return &ErrBudgetExceeded{uri, "run", this.budget.PerRun, this.spent, cost}
+	}
+	now := time.Now()
+	for _, period := range []struct {
+		name  string
+		limit float64
+		since time.Time
+	}{
+		{"daily", this.budget.Daily, startOfDay(now)},
+		{"monthly", this.budget.Monthly, startOfMonth(now)},
+	} {
+		if period.limit <= 0 {
+			continue
+		}
+		// Include operations which have been submitted but not yet recorded
+		spent := this.pending
+		if this.ledger != nil {
+			if recorded, err := this.ledger.Spend(period.since); err != nil {
+				return err
+			} else {
+				spent += recorded
+			}
+		}
+		if spent+cost > period.limit {
+			return &ErrBudgetExceeded{uri, period.name, period.limit, spent, cost}
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e *ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("%v: Budget exceeded: %v limit %.2f, spent %.2f, estimated cost %.2f", e.Uri, e.Period, e.Limit, e.Spent, e.Cost)
+}
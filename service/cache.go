@@ -0,0 +1,254 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Cache stores annotations on disk, keyed by a fingerprint of the input
+// content, the features and the detection configuration
+type Cache struct {
+	path string
+	ttl  time.Duration
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type cacheEntry struct {
+	Key         string
+	Uri         string
+	Created     time.Time
+	Annotations *Annotations
+}
+
+type objectMetadata struct {
+	Generation string `json:"generation"`
+	Etag       string `json:"etag"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Prefix for the names of operations which are returned from the cache
+	cache_OPERATION_PREFIX = "cache/"
+	// Extension for cache entries
+	cache_EXTENSION = ".json"
+	// Endpoint for Cloud Storage object metadata
+	storage_OBJECT_URL = "https://storage.googleapis.com/storage/v1/b/%v/o/%v?fields=generation,etag"
+)
+
+var (
+	ErrNotCacheable = errors.New("Input cannot be cached")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewCache returns a cache which stores entries in a folder, creating the
+// folder if necessary. Entries older than the ttl are ignored, or when ttl
+// is zero entries never expire
+func NewCache(path string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	return &Cache{path, ttl}, nil
+}
+
+// Get returns the annotations for a key, or ErrNotFound if there is no entry
+// or the entry has expired
+func (this *Cache) Get(key string) (*Annotations, error) {
+	entry, err := this.read(this.filename(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if this.expired(entry) {
+		if err := this.Invalidate(key); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	return entry.Annotations, nil
+}
+
+// Set stores the annotations for a key
+func (this *Cache) Set(key, uri string, annotations *Annotations) error {
+	data, err := json.Marshal(&cacheEntry{key, uri, time.Now(), annotations})
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename so readers never see a partial entry
+	temp, err := ioutil.TempFile(this.path, key)
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), this.filename(key))
+}
+
+// Invalidate removes the entry for a key
+func (this *Cache) Invalidate(key string) error {
+	if err := os.Remove(this.filename(key)); err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+	return nil
+}
+
+// Purge removes all expired entries, or all entries if all is true
+func (this *Cache) Purge(all bool) error {
+	filenames, err := filepath.Glob(filepath.Join(this.path, "*"+cache_EXTENSION))
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		if entry, err := this.read(filename); err != nil || all || this.expired(entry) {
+			if err := os.Remove(filename); err != nil && os.IsNotExist(err) == false {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetCache sets the cache used by Annotate, or nil to disable caching
+func (this *Service) SetCache(cache *Cache) {
+	this.cache = cache
+}
+
+// InvalidateCache removes any cached annotations for an input
+func (this *Service) InvalidateCache(uri string, flags AnnotationType, config *Config) error {
+	if this.cache == nil {
+		return nil
+	}
+	if key, err := this.Fingerprint(uri, flags, config); err != nil {
+		return err
+	} else {
+		return this.cache.Invalidate(key)
+	}
+}
+
+// Fingerprint returns a key which identifies the input content, the features,
+// the detection configuration and the API version. Local files are identified
+// by the hash of their content, and Cloud Storage objects by their generation
+// and etag. Returns ErrNotCacheable for wildcard URIs, and for objects where
+// the metadata can't be read, such as without the storage.objects.get
+// permission
+func (this *Service) Fingerprint(uri string, flags AnnotationType, config *Config) (string, error) {
+	hash := sha256.New()
+	if IsCloudStorageUri(uri) {
+		if metadata, err := this.objectMetadata(uri); err != nil {
+			return "", err
+		} else {
+			fmt.Fprintf(hash, "uri=%v\ngeneration=%v\netag=%v\n", uri, metadata.Generation, metadata.Etag)
+		}
+	} else if content, err := contentHash(uri); err != nil {
+		return "", err
+	} else {
+		fmt.Fprintf(hash, "sha256=%v\n", content)
+	}
+	fmt.Fprintf(hash, "features=%v\n", strings.Join(annotateFlagArray(flags), ","))
+	// Results differ between API versions. The default version is not
+	// included so that existing cache entries remain valid
+	if this.version != VERSION_V1BETA2 {
+		fmt.Fprintf(hash, "version=%v\n", this.version)
+	}
+	if config != nil {
+		if data, err := json.Marshal(config); err != nil {
+			return "", err
+		} else {
+			fmt.Fprintf(hash, "config=%s\n", data)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (this *Cache) filename(key string) string {
+	return filepath.Join(this.path, key+cache_EXTENSION)
+}
+
+func (this *Cache) read(filename string) (*cacheEntry, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	entry := new(cacheEntry)
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (this *Cache) expired(entry *cacheEntry) bool {
+	return this.ttl > 0 && time.Since(entry.Created) > this.ttl
+}
+
+// objectMetadata returns the generation and etag for a Cloud Storage object
+func (this *Service) objectMetadata(uri string) (*objectMetadata, error) {
+	parts := strings.SplitN(strings.TrimPrefix(uri, cloud_STORAGE_SCHEME), "/", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidUri
+	}
+	if strings.ContainsAny(parts[1], "*?") {
+		return nil, ErrNotCacheable
+	}
+	response, err := this.client.Get(fmt.Sprintf(storage_OBJECT_URL, url.PathEscape(parts[0]), url.PathEscape(parts[1])))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusNotFound {
+		// The video can still be annotated, but isn't cached
+		log.Printf("%v: Not cached: %v", uri, response.Status)
+		return nil, ErrNotCacheable
+	} else if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v: %v", uri, response.Status)
+	}
+	metadata := new(objectMetadata)
+	if err := json.NewDecoder(response.Body).Decode(metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// contentHash returns the SHA-256 hash of a local file
+func contentHash(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
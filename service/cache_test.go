@@ -0,0 +1,117 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// storageTransport returns a status for Cloud Storage metadata requests,
+// and sends other requests to the default transport
+type storageTransport struct {
+	status   int
+	requests int
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestFingerprintStorage(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusOK, nil},
+		{http.StatusForbidden, ErrNotCacheable},
+		{http.StatusNotFound, ErrNotCacheable},
+	}
+	for _, test := range tests {
+		service, err := NewServiceWithClient(&http.Client{Transport: &storageTransport{status: test.status}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key, err := service.Fingerprint("gs://bucket/video.mp4", ANNOTATION_LABEL, nil); err != test.err {
+			t.Errorf("%v: Expected %v, got %v", test.status, test.err, err)
+		} else if err == nil && len(key) != 64 {
+			t.Errorf("%v: Unexpected key %q", test.status, key)
+		}
+	}
+
+	// Other errors are returned
+	service, err := NewServiceWithClient(&http.Client{Transport: &storageTransport{status: http.StatusInternalServerError}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Fingerprint("gs://bucket/video.mp4", ANNOTATION_LABEL, nil); err == nil || err == ErrNotCacheable {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if _, err := service.Fingerprint("gs://bucket/*.mp4", ANNOTATION_LABEL, nil); err != ErrNotCacheable {
+		t.Errorf("Expected %v, got %v", ErrNotCacheable, err)
+	}
+}
+
+func TestAnnotateForbiddenMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`{"name":"op1"}`))
+		} else {
+			w.Write([]byte(`{"name":"op1","done":true,"response":{"annotationResults":[{"inputUri":"/bucket/video.mp4","shotAnnotations":[{"startTimeOffset":"0s","endTimeOffset":"5s"}]}]}}`))
+		}
+	}))
+	defer server.Close()
+	path, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	cache, err := NewCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &storageTransport{status: http.StatusForbidden}
+	service, err := NewServiceWithClient(&http.Client{Transport: transport}, WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.SetCache(cache)
+	name, err := service.Annotate("gs://bucket/video.mp4", ANNOTATION_SHOT_CHANGE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := service.Status(name); err != nil {
+		t.Fatal(err)
+	} else if status.Cached || len(status.Annotations.Shots) != 1 {
+		t.Errorf("Unexpected status %v", status)
+	}
+	if transport.requests != 1 {
+		t.Errorf("Unexpected metadata requests %v", transport.requests)
+	}
+	if entries, err := filepath.Glob(filepath.Join(path, "*")); err != nil {
+		t.Error(err)
+	} else if len(entries) != 0 {
+		t.Errorf("Unexpected cache entries %v", entries)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func (this *storageTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host != "storage.googleapis.com" {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+	this.requests++
+	recorder := httptest.NewRecorder()
+	recorder.WriteHeader(this.status)
+	if this.status == http.StatusOK {
+		recorder.Write([]byte(`{"generation":"1","etag":"abc"}`))
+	}
+	return recorder.Result(), nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Config defines optional detection parameters for an annotation request
+type Config struct {
+	// Segments of the video to annotate, or the whole video when empty. The
+	// confidence of each segment is ignored
+	Segments []*Segment
+
+	// LabelDetectionMode determines whether shot or frame labels are detected
+	LabelDetectionMode LabelDetectionMode
+
+	// StationaryCamera may improve label detection for a non-moving camera
+	StationaryCamera bool
+
+	// Model is "builtin/stable" or "builtin/latest", or empty for the default
+	Model string
+
+	// LocationId is the cloud region for annotation, or empty for the default
+	LocationId string
+
+	// TextLanguageHints are BCP-47 language codes, such as en-US, for text
+	// detection. Requires the v1 API
+	TextLanguageHints []string `json:",omitempty"`
+
+	// Speech sets the parameters for speech transcription, or nil for the
+	// defaults. Requires the v1 API
+	Speech *SpeechConfig `json:",omitempty"`
+
+	// PersonAttributes and PersonLandmarks return the attributes, such as
+	// clothing, and the pose landmarks of detected people
+	PersonAttributes bool `json:",omitempty"`
+	PersonLandmarks  bool `json:",omitempty"`
+
+	// FaceAttributes returns the attributes, such as expression, of
+	// detected faces
+	FaceAttributes bool `json:",omitempty"`
+}
+
+// SpeechConfig defines the parameters for speech transcription
+type SpeechConfig struct {
+	// LanguageCode is a BCP-47 language code, or empty for en-US
+	LanguageCode string
+
+	// MaxAlternatives is the maximum number of transcripts for each section
+	// of audio, or zero for one
+	MaxAlternatives int64
+
+	// FilterProfanity replaces all but the first letter of profanities
+	// with asterisks
+	FilterProfanity bool
+
+	// Punctuation adds punctuation to transcripts
+	Punctuation bool
+
+	// Speakers is the expected number of speakers, which enables speaker
+	// diarization when greater than zero
+	Speakers int64
+
+	// Phrases are words and phrases which are likely to be spoken, such as
+	// names
+	Phrases []string
+}
+
+// LabelDetectionMode determines which labels are detected
+type LabelDetectionMode uint
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Default language for speech transcription
+	speech_LANGUAGE_CODE = "en-US"
+)
+
+const (
+	LABEL_MODE_UNSPECIFIED LabelDetectionMode = iota
+	LABEL_MODE_SHOT
+	LABEL_MODE_FRAME
+	LABEL_MODE_SHOT_AND_FRAME
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// durationString returns a duration in the format used by the API
+func durationString(value time.Duration) string {
+	return fmt.Sprintf("%.9fs", value.Seconds())
+}
+
+// apiValue returns the enumeration value used by the API
+func (m LabelDetectionMode) apiValue() string {
+	switch m {
+	case LABEL_MODE_SHOT:
+		return "SHOT_MODE"
+	case LABEL_MODE_FRAME:
+		return "FRAME_MODE"
+	case LABEL_MODE_SHOT_AND_FRAME:
+		return "SHOT_AND_FRAME_MODE"
+	default:
+		return ""
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (m LabelDetectionMode) String() string {
+	switch m {
+	case LABEL_MODE_SHOT:
+		return "LABEL_MODE_SHOT"
+	case LABEL_MODE_FRAME:
+		return "LABEL_MODE_FRAME"
+	case LABEL_MODE_SHOT_AND_FRAME:
+		return "LABEL_MODE_SHOT_AND_FRAME"
+	default:
+		return "LABEL_MODE_UNSPECIFIED"
+	}
+}
+
+func (this *Config) String() string {
+	return fmt.Sprintf("Config{ segments=%v label_mode=%v stationary_camera=%v model=%v location=%v text_language_hints=%v speech=%v person_attributes=%v person_landmarks=%v face_attributes=%v }", this.Segments, this.LabelDetectionMode, this.StationaryCamera, this.Model, this.LocationId, this.TextLanguageHints, this.Speech, this.PersonAttributes, this.PersonLandmarks, this.FaceAttributes)
+}
+
+func (this *SpeechConfig) String() string {
+	return fmt.Sprintf("SpeechConfig{ language=%v alternatives=%v filter_profanity=%v punctuation=%v speakers=%v phrases=%q }", this.LanguageCode, this.MaxAlternatives, this.FilterProfanity, this.Punctuation, this.Speakers, this.Phrases)
+}
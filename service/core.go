@@ -0,0 +1,197 @@
+package service
+
+import (
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	// Labels, shots and explicit content are decoded by each API version
+	label_feature = &Feature{
+		Type:   ANNOTATION_LABEL,
+		Name:   "LABEL_DETECTION",
+		Render: renderLabels,
+		Snap:   snapLabels,
+	}
+	shot_feature = &Feature{
+		Type:   ANNOTATION_SHOT_CHANGE,
+		Name:   "SHOT_CHANGE_DETECTION",
+		Render: renderShots,
+		Snap:   snapShots,
+	}
+	explicit_feature = &Feature{
+		Type:   ANNOTATION_EXPLICIT_CONTENT,
+		Name:   "EXPLICIT_CONTENT_DETECTION",
+		Render: renderExplicit,
+		Snap:   snapExplicit,
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// ExplicitDimensions returns the types of explicit content which have a
+// likelihood in any frame
+func (this *Annotations) ExplicitDimensions() []ExplicitDimension {
+	dimensions := make([]ExplicitDimension, 0, int(EXPLICIT_MAX))
+	for dimension := EXPLICIT_ADULT; dimension < EXPLICIT_MAX; dimension++ {
+		for _, frame := range this.ExplicitContent {
+			if frame.Get(dimension) != LIKELIHOOD_UNSPECIFIED {
+				dimensions = append(dimensions, dimension)
+				break
+			}
+		}
+	}
+	return dimensions
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func renderShots(annotations *Annotations) []*Row {
+	rows := make([]*Row, 0, len(annotations.Shots))
+	for _, shot := range annotations.Shots {
+		rows = append(rows, &Row{
+			Type:  "shot",
+			Start: shot.StartOffset,
+			End:   shot.EndOffset,
+			Value: shot,
+		})
+	}
+	return rows
+}
+
+func renderLabels(annotations *Annotations) []*Row {
+	rows := make([]*Row, 0)
+	rows = appendLabelRows(rows, "shot_label", annotations.ShotLabels)
+	rows = appendLabelRows(rows, "segment_label", annotations.SegmentLabels)
+	rows = appendLabelRows(rows, "frame_label", annotations.FrameLabels)
+	return rows
+}
+
+// appendLabelRows adds a row for each segment of each label, where the
+// description includes the categories and only the first row for a label
+// has the type and entity
+func appendLabelRows(rows []*Row, t string, labels []*EntityAnnotation) []*Row {
+	for _, label := range labels {
+		description := label.Entity.Description
+		for i, category := range label.Categories {
+			if i == 0 {
+				description = description + " > "
+			} else {
+				description = description + ", "
+			}
+			description = description + category.Description
+		}
+		for i, segment := range label.Segments {
+			row := &Row{
+				Start:      segment.StartOffset,
+				End:        segment.EndOffset,
+				Confidence: segment.Confidence,
+			}
+			if i == 0 {
+				row.Type, row.Entity, row.Description, row.Value = t, label.Entity.EntityId, description, label
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// renderExplicit adds a row for each type of explicit content in each frame
+func renderExplicit(annotations *Annotations) []*Row {
+	rows := make([]*Row, 0)
+	dimensions := annotations.ExplicitDimensions()
+	for _, frame := range annotations.ExplicitContent {
+		for _, dimension := range dimensions {
+			rows = append(rows, &Row{
+				Type:        "explicit_content",
+				Description: strings.ToLower(strings.TrimPrefix(dimension.String(), "EXPLICIT_")),
+				Start:       frame.Offset,
+				End:         frame.Offset,
+				Confidence:  frame.Get(dimension),
+				Value:       frame,
+			})
+		}
+	}
+	return rows
+}
+
+// renderTracks adds a row for each track, where the description is the
+// entity or the attributes of the track
+func renderTracks(t string, entity *Entity, tracks []*Track) []*Row {
+	rows := make([]*Row, 0, len(tracks))
+	for _, track := range tracks {
+		row := &Row{
+			Type:       t,
+			Start:      track.StartOffset,
+			End:        track.EndOffset,
+			Confidence: track.Confidence,
+			Value:      track,
+		}
+		if entity != nil {
+			row.Entity, row.Description = entity.EntityId, entity.Description
+		} else {
+			attributes := make([]string, 0, len(track.Attributes))
+			for _, attribute := range track.Attributes {
+				if attribute.Value != "" {
+					attributes = append(attributes, attribute.Name+"="+attribute.Value)
+				} else {
+					attributes = append(attributes, attribute.Name)
+				}
+			}
+			row.Description = strings.Join(attributes, ", ")
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func snapShots(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+	snapped.Shots = make([]*ShotAnnotation, len(annotations.Shots))
+	for i, shot := range annotations.Shots {
+		snapped.Shots[i] = &ShotAnnotation{snap(shot.StartOffset), snap(shot.EndOffset)}
+	}
+}
+
+func snapLabels(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+	snapped.ShotLabels = snapEntityAnnotations(annotations.ShotLabels, snap)
+	snapped.SegmentLabels = snapEntityAnnotations(annotations.SegmentLabels, snap)
+	snapped.FrameLabels = snapEntityAnnotations(annotations.FrameLabels, snap)
+}
+
+func snapExplicit(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+	snapped.ExplicitContent = make([]*ExplicitContentAnnotation, len(annotations.ExplicitContent))
+	for i, annotation := range annotations.ExplicitContent {
+		frame := *annotation
+		frame.Offset = snap(annotation.Offset)
+		snapped.ExplicitContent[i] = &frame
+	}
+}
+
+func snapEntityAnnotations(labels []*EntityAnnotation, snap func(time.Duration) time.Duration) []*EntityAnnotation {
+	snapped := make([]*EntityAnnotation, len(labels))
+	for i, label := range labels {
+		snapped[i] = &EntityAnnotation{
+			Entity:     label.Entity,
+			Categories: label.Categories,
+			Segments:   snapSegments(label.Segments, snap),
+		}
+	}
+	return snapped
+}
+
+func snapSegments(segments []*Segment, snap func(time.Duration) time.Duration) []*Segment {
+	snapped := make([]*Segment, len(segments))
+	for i, segment := range segments {
+		snapped[i] = &Segment{
+			StartOffset: snap(segment.StartOffset),
+			EndOffset:   snap(segment.EndOffset),
+			Confidence:  segment.Confidence,
+		}
+	}
+	return snapped
+}
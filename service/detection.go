@@ -0,0 +1,343 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// personResponse, faceResponse and logoResponse decode results which
+// aren't included in the checked-in discovery documents
+type personResponse struct {
+	AnnotationResults []struct {
+		PersonDetectionAnnotations []*struct {
+			Tracks []*detectionTrack `json:"tracks"`
+		} `json:"personDetectionAnnotations"`
+	} `json:"annotationResults"`
+}
+
+type faceResponse struct {
+	AnnotationResults []struct {
+		FaceDetectionAnnotations []*struct {
+			Tracks    []*detectionTrack `json:"tracks"`
+			Thumbnail string            `json:"thumbnail"`
+		} `json:"faceDetectionAnnotations"`
+	} `json:"annotationResults"`
+}
+
+type logoResponse struct {
+	AnnotationResults []struct {
+		LogoRecognitionAnnotations []*struct {
+			Entity   *detectionEntity   `json:"entity"`
+			Tracks   []*detectionTrack  `json:"tracks"`
+			Segments []*detectionOffset `json:"segments"`
+		} `json:"logoRecognitionAnnotations"`
+	} `json:"annotationResults"`
+}
+
+type detectionEntity struct {
+	EntityId     string `json:"entityId"`
+	Description  string `json:"description"`
+	LanguageCode string `json:"languageCode"`
+}
+
+type detectionOffset struct {
+	StartTimeOffset string `json:"startTimeOffset"`
+	EndTimeOffset   string `json:"endTimeOffset"`
+}
+
+type detectionTrack struct {
+	Segment            *detectionOffset      `json:"segment"`
+	Confidence         float64               `json:"confidence"`
+	Attributes         []*detectionAttribute `json:"attributes"`
+	TimestampedObjects []*struct {
+		TimeOffset            string `json:"timeOffset"`
+		NormalizedBoundingBox *struct {
+			Left   float64 `json:"left"`
+			Top    float64 `json:"top"`
+			Right  float64 `json:"right"`
+			Bottom float64 `json:"bottom"`
+		} `json:"normalizedBoundingBox"`
+		Attributes []*detectionAttribute `json:"attributes"`
+		Landmarks  []*struct {
+			Name  string `json:"name"`
+			Point *struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"point"`
+			Confidence float64 `json:"confidence"`
+		} `json:"landmarks"`
+	} `json:"timestampedObjects"`
+}
+
+type detectionAttribute struct {
+	Name       string  `json:"name"`
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+type personDetectionConfig struct {
+	IncludeBoundingBoxes bool `json:"includeBoundingBoxes"`
+	IncludeAttributes    bool `json:"includeAttributes,omitempty"`
+	IncludePoseLandmarks bool `json:"includePoseLandmarks,omitempty"`
+}
+
+type faceDetectionConfig struct {
+	Model                string `json:"model,omitempty"`
+	IncludeBoundingBoxes bool   `json:"includeBoundingBoxes"`
+	IncludeAttributes    bool   `json:"includeAttributes,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	person_feature = &Feature{
+		Type: ANNOTATION_PERSON_DETECTION,
+		Name: "PERSON_DETECTION",
+		Context: func(config *Config) (string, interface{}) {
+			context := &personDetectionConfig{IncludeBoundingBoxes: true}
+			if config != nil {
+				context.IncludeAttributes = config.PersonAttributes
+				context.IncludePoseLandmarks = config.PersonLandmarks
+			}
+			return "personDetectionConfig", context
+		},
+		Decode: decodePeople,
+		Render: func(annotations *Annotations) []*Row {
+			rows := make([]*Row, 0)
+			for _, person := range annotations.People {
+				rows = append(rows, renderTracks("person", nil, person.Tracks)...)
+			}
+			return rows
+		},
+		Snap: func(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+			snapped.People = make([]*PersonAnnotation, len(annotations.People))
+			for i, person := range annotations.People {
+				snapped.People[i] = &PersonAnnotation{Tracks: snapTracks(person.Tracks, snap)}
+			}
+		},
+	}
+	face_feature = &Feature{
+		Type: ANNOTATION_FACE_DETECTION,
+		Name: "FACE_DETECTION",
+		Context: func(config *Config) (string, interface{}) {
+			context := &faceDetectionConfig{IncludeBoundingBoxes: true}
+			if config != nil {
+				context.Model = config.Model
+				context.IncludeAttributes = config.FaceAttributes
+			}
+			return "faceDetectionConfig", context
+		},
+		Decode: decodeFaces,
+		Render: func(annotations *Annotations) []*Row {
+			rows := make([]*Row, 0)
+			for _, face := range annotations.Faces {
+				rows = append(rows, renderTracks("face", nil, face.Tracks)...)
+			}
+			return rows
+		},
+		Snap: func(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+			snapped.Faces = make([]*FaceAnnotation, len(annotations.Faces))
+			for i, face := range annotations.Faces {
+				snapped.Faces[i] = &FaceAnnotation{Tracks: snapTracks(face.Tracks, snap), Thumbnail: face.Thumbnail}
+			}
+		},
+	}
+	logo_feature = &Feature{
+		Type:   ANNOTATION_LOGO_RECOGNITION,
+		Name:   "LOGO_RECOGNITION",
+		Decode: decodeLogos,
+		Render: func(annotations *Annotations) []*Row {
+			rows := make([]*Row, 0)
+			for _, logo := range annotations.Logos {
+				rows = append(rows, renderTracks("logo", logo.Entity, logo.Tracks)...)
+			}
+			return rows
+		},
+		Snap: func(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+			snapped.Logos = make([]*LogoAnnotation, len(annotations.Logos))
+			for i, logo := range annotations.Logos {
+				snapped.Logos[i] = &LogoAnnotation{Entity: logo.Entity, Tracks: snapTracks(logo.Tracks, snap), Segments: snapSegments(logo.Segments, snap)}
+			}
+		},
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Tracks returns the tracks for all detected people, faces and logos
+func (this *Annotations) Tracks() []*Track {
+	tracks := make([]*Track, 0)
+	for _, person := range this.People {
+		tracks = append(tracks, person.Tracks...)
+	}
+	for _, face := range this.Faces {
+		tracks = append(tracks, face.Tracks...)
+	}
+	for _, logo := range this.Logos {
+		tracks = append(tracks, logo.Tracks...)
+	}
+	return tracks
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// decodePeople appends detected people from an operation response
+func decodePeople(annotations *Annotations, response []byte) error {
+	var results personResponse
+	if err := json.Unmarshal(response, &results); err != nil {
+		return err
+	}
+	for _, result := range results.AnnotationResults {
+		for _, annotation := range result.PersonDetectionAnnotations {
+			person := &PersonAnnotation{}
+			if err := decodeTracks(&person.Tracks, annotation.Tracks); err != nil {
+				return err
+			}
+			annotations.People = append(annotations.People, person)
+		}
+	}
+	return nil
+}
+
+// decodeFaces appends detected faces from an operation response
+func decodeFaces(annotations *Annotations, response []byte) error {
+	var results faceResponse
+	if err := json.Unmarshal(response, &results); err != nil {
+		return err
+	}
+	for _, result := range results.AnnotationResults {
+		for _, annotation := range result.FaceDetectionAnnotations {
+			face := &FaceAnnotation{}
+			if err := decodeTracks(&face.Tracks, annotation.Tracks); err != nil {
+				return err
+			}
+			if annotation.Thumbnail != "" {
+				if thumbnail, err := base64.StdEncoding.DecodeString(annotation.Thumbnail); err != nil {
+					return err
+				} else {
+					face.Thumbnail = thumbnail
+				}
+			}
+			annotations.Faces = append(annotations.Faces, face)
+		}
+	}
+	return nil
+}
+
+// decodeLogos appends recognised logos from an operation response
+func decodeLogos(annotations *Annotations, response []byte) error {
+	var results logoResponse
+	if err := json.Unmarshal(response, &results); err != nil {
+		return err
+	}
+	for _, result := range results.AnnotationResults {
+		for _, annotation := range result.LogoRecognitionAnnotations {
+			logo := &LogoAnnotation{Entity: &Entity{}, Segments: make([]*Segment, 0, len(annotation.Segments))}
+			if annotation.Entity != nil {
+				logo.Entity = &Entity{annotation.Entity.EntityId, annotation.Entity.Description, annotation.Entity.LanguageCode}
+			}
+			if err := decodeTracks(&logo.Tracks, annotation.Tracks); err != nil {
+				return err
+			}
+			for _, segment := range annotation.Segments {
+				if segment, err := segment.decode(0); err != nil {
+					return err
+				} else {
+					logo.Segments = append(logo.Segments, segment)
+				}
+			}
+			annotations.Logos = append(annotations.Logos, logo)
+		}
+	}
+	return nil
+}
+
+// decodeTracks appends tracks from a response
+func decodeTracks(tracks *[]*Track, values []*detectionTrack) error {
+	for _, value := range values {
+		track := &Track{
+			Confidence: value.Confidence,
+			Attributes: decodeAttributes(value.Attributes),
+			Frames:     make([]*TrackFrame, 0, len(value.TimestampedObjects)),
+		}
+		if value.Segment != nil {
+			if segment, err := value.Segment.decode(value.Confidence); err != nil {
+				return err
+			} else {
+				track.StartOffset, track.EndOffset = segment.StartOffset, segment.EndOffset
+			}
+		}
+		for _, object := range value.TimestampedObjects {
+			offset, err := parseOffset(object.TimeOffset)
+			if err != nil {
+				return err
+			}
+			frame := &TrackFrame{
+				Offset:     offset,
+				Box:        &BoundingBox{},
+				Attributes: decodeAttributes(object.Attributes),
+				Landmarks:  make([]*Landmark, 0, len(object.Landmarks)),
+			}
+			if box := object.NormalizedBoundingBox; box != nil {
+				frame.Box = &BoundingBox{box.Left, box.Top, box.Right, box.Bottom}
+			}
+			for _, landmark := range object.Landmarks {
+				point := &Landmark{Name: landmark.Name, Confidence: landmark.Confidence}
+				if landmark.Point != nil {
+					point.X, point.Y = landmark.Point.X, landmark.Point.Y
+				}
+				frame.Landmarks = append(frame.Landmarks, point)
+			}
+			track.Frames = append(track.Frames, frame)
+		}
+		*tracks = append(*tracks, track)
+	}
+	return nil
+}
+
+// snapTracks returns a copy of tracks with each offset aligned by the snap
+// function
+func snapTracks(tracks []*Track, snap func(time.Duration) time.Duration) []*Track {
+	snapped := make([]*Track, len(tracks))
+	for i, track := range tracks {
+		snappedTrack := *track
+		snappedTrack.StartOffset = snap(track.StartOffset)
+		snappedTrack.EndOffset = snap(track.EndOffset)
+		snappedTrack.Frames = make([]*TrackFrame, len(track.Frames))
+		for j, frame := range track.Frames {
+			snappedFrame := *frame
+			snappedFrame.Offset = snap(frame.Offset)
+			snappedTrack.Frames[j] = &snappedFrame
+		}
+		snapped[i] = &snappedTrack
+	}
+	return snapped
+}
+
+func decodeAttributes(values []*detectionAttribute) []*Attribute {
+	attributes := make([]*Attribute, 0, len(values))
+	for _, value := range values {
+		attributes = append(attributes, &Attribute{value.Name, value.Value, value.Confidence})
+	}
+	return attributes
+}
+
+// decode returns a segment with a confidence
+func (this *detectionOffset) decode(confidence float64) (*Segment, error) {
+	start, err := parseOffset(this.StartTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseOffset(this.EndTimeOffset)
+	if err != nil {
+		return nil, err
+	}
+	return &Segment{start, end, confidence}, nil
+}
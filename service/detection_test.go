@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestDetectionContext(t *testing.T) {
+	flags := ANNOTATION_PERSON_DETECTION | ANNOTATION_FACE_DETECTION | ANNOTATION_LOGO_RECOGNITION
+	config := &Config{PersonLandmarks: true, FaceAttributes: true, Model: "builtin/latest"}
+	_, request := annotateFixture(t, VERSION_V1, flags, config, "detection.json")
+	videoContext, _ := request["videoContext"].(map[string]interface{})
+	if person, _ := videoContext["personDetectionConfig"].(map[string]interface{}); person["includeBoundingBoxes"] != true || person["includePoseLandmarks"] != true || person["includeAttributes"] != nil {
+		t.Errorf("Unexpected person detection config %v", person)
+	}
+	if face, _ := videoContext["faceDetectionConfig"].(map[string]interface{}); face["includeBoundingBoxes"] != true || face["includeAttributes"] != true || face["model"] != "builtin/latest" {
+		t.Errorf("Unexpected face detection config %v", face)
+	}
+}
+
+func TestPersonLandmarks(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_PERSON_DETECTION, nil, "detection.json")
+	person := status.Annotations.People[0].Tracks[0]
+	if len(person.Attributes) != 1 || person.Attributes[0].Name != "UpperCloth" || person.Attributes[0].Value != "Red" {
+		t.Errorf("Unexpected attributes %v", person.Attributes)
+	}
+	if landmarks := person.Frames[0].Landmarks; len(landmarks) != 1 || landmarks[0].Name != "nose" || landmarks[0].X != 0.2 || landmarks[0].Confidence != 0.7 {
+		t.Errorf("Unexpected landmarks %v", landmarks)
+	}
+}
+
+func TestFaceThumbnail(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_FACE_DETECTION, nil, "detection.json")
+	if faces := status.Annotations.Faces; len(faces) != 1 || string(faces[0].Thumbnail) != "\x01\x02\x03" {
+		t.Errorf("Unexpected faces %v", faces)
+	}
+}
+
+func TestLogoSegments(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_LOGO_RECOGNITION, nil, "detection.json")
+	logo := status.Annotations.Logos[0]
+	if logo.Entity.EntityId != "/m/045c7b" || logo.Entity.Description != "Google" {
+		t.Errorf("Unexpected entity %v", logo.Entity)
+	}
+	// Logo segments have no confidence, unlike the tracks
+	if len(logo.Segments) != 1 || logo.Segments[0].Confidence != 0 || logo.Tracks[0].Confidence != 0.95 {
+		t.Errorf("Unexpected segments %v", logo.Segments)
+	}
+	if tracks := status.Annotations.Tracks(); len(tracks) != 3 {
+		t.Errorf("Expected three tracks, got %v", len(tracks))
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/probe"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// EstimateConfig defines the detection parameters, prices and known video
+// durations used to estimate the cost of annotation
+type EstimateConfig struct {
+	Config
+
+	// Pricing is the price table, or nil for the default prices
+	Pricing *Pricing
+
+	// Manifest is the duration of each input, which is used in preference
+	// to reading the duration from local files
+	Manifest map[string]time.Duration
+}
+
+// Pricing defines the price per billable minute for each feature, and the
+// number of free minutes remaining for each feature
+type Pricing struct {
+	Currency    string
+	PerMinute   map[AnnotationType]float64
+	FreeMinutes map[AnnotationType]int64
+}
+
+// CostEstimate defines the estimated cost for a set of inputs
+type CostEstimate struct {
+	Currency    string
+	Videos      []*VideoEstimate
+	Minutes     map[AnnotationType]int64
+	FreeMinutes map[AnnotationType]int64
+	Cost        map[AnnotationType]float64
+	Total       float64
+}
+
+// VideoEstimate defines the estimated cost for a single input, after any
+// free minutes have been applied
+type VideoEstimate struct {
+	Uri         string
+	Duration    time.Duration
+	Minutes     map[AnnotationType]int64
+	FreeMinutes map[AnnotationType]int64
+	Cost        map[AnnotationType]float64
+	Total       float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+type pricingJSON struct {
+	Currency    string             `json:"currency"`
+	PerMinute   map[string]float64 `json:"per_minute"`
+	FreeMinutes map[string]int64   `json:"free_minutes"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	ErrUnknownDuration = errors.New("Unknown duration, add the input to the manifest")
+	ErrInvalidManifest = errors.New("Invalid manifest")
+	ErrInvalidPricing  = errors.New("Invalid pricing")
+)
+
+var (
+	// DefaultPricing is the published price per minute in US dollars, with
+	// the monthly free allowance for each feature
+	DefaultPricing = &Pricing{
+		Currency: "USD",
+		PerMinute: map[AnnotationType]float64{
+			ANNOTATION_LABEL:            0.10,
+			ANNOTATION_SHOT_CHANGE:      0.05,
+			ANNOTATION_EXPLICIT_CONTENT: 0.10,
+			ANNOTATION_OBJECT_TRACKING:  0.15,
+			ANNOTATION_TEXT_DETECTION:   0.15,
+			ANNOTATION_SPEECH:           0.048,
+			ANNOTATION_PERSON_DETECTION: 0.10,
+			ANNOTATION_FACE_DETECTION:   0.10,
+			ANNOTATION_LOGO_RECOGNITION: 0.15,
+		},
+		FreeMinutes: map[AnnotationType]int64{
+			ANNOTATION_LABEL:            1000,
+			ANNOTATION_SHOT_CHANGE:      1000,
+			ANNOTATION_EXPLICIT_CONTENT: 1000,
+			ANNOTATION_OBJECT_TRACKING:  1000,
+			ANNOTATION_TEXT_DETECTION:   1000,
+			ANNOTATION_SPEECH:           1000,
+			ANNOTATION_PERSON_DETECTION: 1000,
+			ANNOTATION_FACE_DETECTION:   1000,
+			ANNOTATION_LOGO_RECOGNITION: 1000,
+		},
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Estimate returns the billable minutes and cost for annotating the inputs,
+// without making any requests. Each feature is billed per started minute of
+// each video, or of the requested segments when set. Free minutes are
+// applied to the inputs in order. Returns InputErrors for any inputs with an
+// unknown duration
+func Estimate(inputs []string, flags AnnotationType, config *EstimateConfig) (*CostEstimate, error) {
+	if config == nil {
+		config = new(EstimateConfig)
+	}
+	pricing := config.Pricing
+	if pricing == nil {
+		pricing = DefaultPricing
+	}
+	estimate := &CostEstimate{
+		Currency:    pricing.Currency,
+		Videos:      make([]*VideoEstimate, 0, len(inputs)),
+		Minutes:     make(map[AnnotationType]int64),
+		FreeMinutes: make(map[AnnotationType]int64),
+		Cost:        make(map[AnnotationType]float64),
+	}
+
+	// Remaining free minutes for each feature
+	free := make(map[AnnotationType]int64, len(pricing.FreeMinutes))
+	for annotationType, minutes := range pricing.FreeMinutes {
+		free[annotationType] = minutes
+	}
+
+	errs := make(InputErrors, 0)
+	for _, uri := range inputs {
+		duration, err := config.billableDuration(uri)
+		if err != nil {
+			errs = append(errs, &InputError{uri, err})
+			continue
+		}
+		video := &VideoEstimate{
+			Uri:         uri,
+			Duration:    duration,
+			Minutes:     make(map[AnnotationType]int64),
+			FreeMinutes: make(map[AnnotationType]int64),
+			Cost:        make(map[AnnotationType]float64),
+		}
+		minutes := int64(math.Ceil(duration.Minutes()))
+		for _, annotationType := range annotateTypeArray(flags) {
+			billable := minutes
+			if free[annotationType] > 0 {
+				allowance := billable
+				if allowance > free[annotationType] {
+					allowance = free[annotationType]
+				}
+				free[annotationType] -= allowance
+				billable -= allowance
+				video.FreeMinutes[annotationType] = allowance
+			}
+			video.Minutes[annotationType] = minutes
+			video.Cost[annotationType] = float64(billable) * pricing.PerMinute[annotationType]
+			video.Total += video.Cost[annotationType]
+			estimate.Minutes[annotationType] += minutes
+			estimate.FreeMinutes[annotationType] += video.FreeMinutes[annotationType]
+			estimate.Cost[annotationType] += video.Cost[annotationType]
+		}
+		estimate.Videos = append(estimate.Videos, video)
+		estimate.Total += video.Total
+	}
+	if len(errs) > 0 {
+		return estimate, errs
+	}
+	return estimate, nil
+}
+
+// ReadManifest returns durations from lines of the form "uri,duration" where
+// the duration is in seconds or a duration such as "1m30s". Blank lines and
+// lines starting with # are ignored
+func ReadManifest(r io.Reader) (map[string]time.Duration, error) {
+	manifest := make(map[string]time.Duration)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := strings.LastIndex(text, ",")
+		if i < 1 {
+			return nil, fmt.Errorf("%v: line %v", ErrInvalidManifest, line)
+		}
+		duration, err := parseManifestDuration(strings.TrimSpace(text[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%v: line %v", ErrInvalidManifest, line)
+		}
+		manifest[strings.TrimSpace(text[:i])] = duration
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadPricing returns a price table from JSON, where the per_minute and
+// free_minutes objects are keyed by feature name (for example
+// "LABEL_DETECTION")
+func ReadPricing(r io.Reader) (*Pricing, error) {
+	var data pricingJSON
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	pricing := &Pricing{
+		Currency:    data.Currency,
+		PerMinute:   make(map[AnnotationType]float64, len(data.PerMinute)),
+		FreeMinutes: make(map[AnnotationType]int64, len(data.FreeMinutes)),
+	}
+	for name, price := range data.PerMinute {
+		if feature := featureNamed(name); feature == nil {
+			return nil, fmt.Errorf("%v: %v", ErrInvalidPricing, name)
+		} else {
+			pricing.PerMinute[feature.Type] = price
+		}
+	}
+	for name, minutes := range data.FreeMinutes {
+		if feature := featureNamed(name); feature == nil {
+			return nil, fmt.Errorf("%v: %v", ErrInvalidPricing, name)
+		} else {
+			pricing.FreeMinutes[feature.Type] = minutes
+		}
+	}
+	return pricing, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// billableDuration returns the duration of the video, or of the requested
+// segments within the video
+func (this *EstimateConfig) billableDuration(uri string) (time.Duration, error) {
+	duration, err := this.videoDuration(uri)
+	if len(this.Segments) == 0 {
+		return duration, err
+	}
+	var total time.Duration
+	for _, segment := range this.Segments {
+		start, end := segment.StartOffset, segment.EndOffset
+		if err == nil && end > duration {
+			end = duration
+		}
+		if end > start {
+			total += end - start
+		}
+	}
+	return total, nil
+}
+
+// videoDuration returns the duration from the manifest or a local file
+func (this *EstimateConfig) videoDuration(uri string) (time.Duration, error) {
+	if duration, exists := this.Manifest[uri]; exists {
+		return duration, nil
+	}
+	if IsCloudStorageUri(uri) {
+		return 0, ErrUnknownDuration
+	}
+	if info, err := probe.Open(uri); err == probe.ErrNotContainer {
+		return 0, ErrUnknownDuration
+	} else if err != nil {
+		return 0, err
+	} else {
+		return info.Duration, nil
+	}
+}
+
+func parseManifestDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, ErrInvalidManifest
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Feature defines how an annotation type is requested, decoded and rendered,
+// so that features can be added without changes to the service, backends or
+// command-line tool. Features which are not built in should store their
+// annotations in Annotations.Extensions, keyed by the feature name, and are
+// supported by all API versions
+type Feature struct {
+	// Type is the annotation type for the feature, which is assigned when
+	// the feature is registered if not set
+	Type AnnotationType
+
+	// Name is the feature in the API, such as LOGO_RECOGNITION
+	Name string
+
+	// Context returns the name and value of the video context field which
+	// configures the feature, or an empty name if there is none. The config
+	// can be nil
+	Context func(config *Config) (string, interface{})
+
+	// Decode appends the annotations for the feature from the JSON
+	// operation response, or is nil if the backend decodes the annotations
+	Decode func(annotations *Annotations, response []byte) error
+
+	// Render returns rows of table output for the annotations, or is nil
+	Render func(annotations *Annotations) []*Row
+
+	// Snap sets the annotations for the feature in snapped to a copy of
+	// the annotations with each offset aligned by the snap function, or is
+	// nil if the annotations have no offsets
+	Snap func(snapped, annotations *Annotations, snap func(time.Duration) time.Duration)
+
+	// Extension returns a pointer to a new value which the extension
+	// annotations are decoded into when read from the cache, or is nil to
+	// decode them as generic JSON values
+	Extension func() interface{}
+}
+
+// Row is a row of table output. Confidence is a float64, a LikelihoodType
+// or nil, and Value is the annotation, which is shown when debugging
+type Row struct {
+	Type        string
+	Entity      string
+	Description string
+	Start       time.Duration
+	End         time.Duration
+	Confidence  interface{}
+	Value       interface{}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// GLOBALS
+
+var (
+	// Registered features, in the order they are rendered
+	features = make([]*Feature, 0)
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	ErrInvalidFeature   = errors.New("Invalid feature")
+	ErrDuplicateFeature = errors.New("Duplicate feature")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// RegisterFeature adds a feature to the registry and returns the annotation
+// type, which is assigned when the feature type is ANNOTATION_NONE. Returns
+// ErrDuplicateFeature if the name or type is already registered, in which
+// case the feature is not changed
+func RegisterFeature(feature *Feature) (AnnotationType, error) {
+	if feature == nil || feature.Name == "" {
+		return ANNOTATION_NONE, ErrInvalidFeature
+	}
+	annotationType := feature.Type
+	if annotationType == ANNOTATION_NONE {
+		annotationType = ANNOTATION_MAX
+		for annotationType != ANNOTATION_NONE && FeatureFor(annotationType) != nil {
+			annotationType <<= 1
+		}
+		if annotationType == ANNOTATION_NONE {
+			return ANNOTATION_NONE, ErrInvalidFeature
+		}
+	} else if annotationType&(annotationType-1) != ANNOTATION_NONE {
+		// The type must be a single flag
+		return ANNOTATION_NONE, ErrInvalidFeature
+	}
+	for _, other := range features {
+		if other.Type == annotationType || strings.EqualFold(other.Name, feature.Name) {
+			return ANNOTATION_NONE, ErrDuplicateFeature
+		}
+	}
+	feature.Type = annotationType
+	features = append(features, feature)
+	return annotationType, nil
+}
+
+// Features returns the registered features
+func Features() []*Feature {
+	return append([]*Feature{}, features...)
+}
+
+// FeatureFor returns the registered feature for an annotation type, or nil
+func FeatureFor(annotationType AnnotationType) *Feature {
+	for _, feature := range features {
+		if feature.Type == annotationType {
+			return feature
+		}
+	}
+	return nil
+}
+
+// ParseFeatures returns annotation types from a comma-separated list of
+// feature names, such as LABEL_DETECTION,LOGO_RECOGNITION, ignoring case
+func ParseFeatures(value string) (AnnotationType, error) {
+	flags := ANNOTATION_NONE
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if feature := featureNamed(name); feature == nil {
+			return ANNOTATION_NONE, fmt.Errorf("%v: %v", ErrInvalidFeature, name)
+		} else {
+			flags |= feature.Type
+		}
+	}
+	return flags, nil
+}
+
+// Render returns rows of table output for all the registered features
+func (this *Annotations) Render() []*Row {
+	rows := make([]*Row, 0)
+	for _, feature := range features {
+		if feature.Render != nil {
+			rows = append(rows, feature.Render(this)...)
+		}
+	}
+	return rows
+}
+
+// Snap returns a copy of the annotations with each offset aligned by the
+// snap function. Extension annotations for features without a Snap hook
+// are copied unchanged
+func (this *Annotations) Snap(snap func(time.Duration) time.Duration) *Annotations {
+	snapped := new(Annotations)
+	if len(this.Extensions) > 0 {
+		snapped.Extensions = make(map[string]interface{}, len(this.Extensions))
+		for name, value := range this.Extensions {
+			snapped.Extensions[name] = value
+		}
+	}
+	for _, feature := range features {
+		if feature.Snap != nil {
+			feature.Snap(snapped, this, snap)
+		}
+	}
+	return snapped
+}
+
+// UnmarshalJSON decodes annotations, where extension annotations are decoded
+// into the value returned by the Extension hook of their feature
+func (this *Annotations) UnmarshalJSON(data []byte) error {
+	type annotations Annotations
+	value := struct {
+		*annotations
+		Extensions map[string]json.RawMessage `json:",omitempty"`
+	}{annotations: (*annotations)(this)}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	this.Extensions = nil
+	for name, data := range value.Extensions {
+		if this.Extensions == nil {
+			this.Extensions = make(map[string]interface{}, len(value.Extensions))
+		}
+		if feature := featureNamed(name); feature != nil && feature.Extension != nil {
+			extension := feature.Extension()
+			if err := json.Unmarshal(data, extension); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+			this.Extensions[name] = reflect.ValueOf(extension).Elem().Interface()
+		} else {
+			var extension interface{}
+			if err := json.Unmarshal(data, &extension); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+			this.Extensions[name] = extension
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func init() {
+	// Built-in features are registered in the order they are rendered
+	for _, feature := range []*Feature{
+		shot_feature, label_feature, explicit_feature, text_feature, speech_feature,
+		person_feature, face_feature, logo_feature, object_feature,
+	} {
+		if _, err := RegisterFeature(feature); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// registeredTypes returns the annotation types of all registered features
+func registeredTypes() AnnotationType {
+	flags := ANNOTATION_NONE
+	for _, feature := range features {
+		flags |= feature.Type
+	}
+	return flags
+}
+
+// extensionTypes returns the annotation types of registered features which
+// are not built in
+func extensionTypes() AnnotationType {
+	return registeredTypes() &^ (ANNOTATION_MAX - 1)
+}
+
+// featureNamed returns the registered feature with a name, ignoring case,
+// or nil
+func featureNamed(name string) *Feature {
+	for _, feature := range features {
+		if strings.EqualFold(feature.Name, name) {
+			return feature
+		}
+	}
+	return nil
+}
+
+// featureContext returns the video context fields for the registered
+// features which are requested
+func featureContext(flags AnnotationType, config *Config) map[string]interface{} {
+	context := make(map[string]interface{})
+	for _, feature := range features {
+		if flags&feature.Type == ANNOTATION_NONE || feature.Context == nil {
+			continue
+		}
+		if name, value := feature.Context(config); name != "" {
+			context[name] = value
+		}
+	}
+	return context
+}
+
+// decodeFeatures appends the annotations for the registered features of
+// the annotation types which decode the JSON operation response
+func decodeFeatures(annotations *Annotations, flags AnnotationType, response []byte) error {
+	for _, feature := range features {
+		if flags&feature.Type == ANNOTATION_NONE || feature.Decode == nil {
+			continue
+		}
+		if err := feature.Decode(annotations, response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeJSON returns the JSON encoding of a value with additional fields,
+// where the value encodes as a JSON object
+func mergeJSON(value interface{}, fields map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil || len(fields) == 0 {
+		return data, err
+	}
+	merged := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for name, field := range fields {
+		if data, err := json.Marshal(field); err != nil {
+			return nil, err
+		} else {
+			merged[name] = data
+		}
+	}
+	return json.Marshal(merged)
+}
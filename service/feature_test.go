@@ -0,0 +1,317 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TEST FEATURE
+
+// landmark is the annotation for a feature which isn't built in
+type landmark struct {
+	Description string
+	Offset      time.Duration
+}
+
+var (
+	landmark_feature = &Feature{
+		Name: "LANDMARK_DETECTION",
+		Context: func(config *Config) (string, interface{}) {
+			return "landmarkDetectionConfig", map[string]interface{}{"model": "builtin/latest"}
+		},
+		Decode: func(annotations *Annotations, response []byte) error {
+			var results struct {
+				AnnotationResults []struct {
+					LandmarkAnnotations []struct {
+						Description string `json:"description"`
+						TimeOffset  string `json:"timeOffset"`
+					} `json:"landmarkAnnotations"`
+				} `json:"annotationResults"`
+			}
+			if err := json.Unmarshal(response, &results); err != nil {
+				return err
+			}
+			landmarks := make([]*landmark, 0)
+			for _, result := range results.AnnotationResults {
+				for _, annotation := range result.LandmarkAnnotations {
+					if offset, err := parseOffset(annotation.TimeOffset); err != nil {
+						return err
+					} else {
+						landmarks = append(landmarks, &landmark{annotation.Description, offset})
+					}
+				}
+			}
+			if annotations.Extensions == nil {
+				annotations.Extensions = make(map[string]interface{})
+			}
+			annotations.Extensions["LANDMARK_DETECTION"] = landmarks
+			return nil
+		},
+		Snap: func(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+			if landmarks, ok := annotations.Extensions["LANDMARK_DETECTION"].([]*landmark); ok {
+				snappedLandmarks := make([]*landmark, len(landmarks))
+				for i, value := range landmarks {
+					snappedLandmarks[i] = &landmark{value.Description, snap(value.Offset)}
+				}
+				snapped.Extensions["LANDMARK_DETECTION"] = snappedLandmarks
+			}
+		},
+		Extension: func() interface{} {
+			return new([]*landmark)
+		},
+	}
+)
+
+var (
+	// Responses in testdata for the built-in features which decode the
+	// operation response, and the number of rows rendered for each
+	feature_fixtures = map[string]struct {
+		fixture string
+		rows    int
+	}{
+		"OBJECT_TRACKING":      {"objects.json", 1},
+		"TEXT_DETECTION":       {"text.json", 2},
+		"SPEECH_TRANSCRIPTION": {"speech.json", 3},
+		"PERSON_DETECTION":     {"detection.json", 1},
+		"FACE_DETECTION":       {"detection.json", 1},
+		"LOGO_RECOGNITION":     {"detection.json", 1},
+	}
+)
+
+// registerLandmarks registers the test feature, which is removed when the
+// test completes
+func registerLandmarks(t *testing.T) AnnotationType {
+	annotationType, err := RegisterFeature(landmark_feature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		features = features[:len(features)-1]
+		landmark_feature.Type = ANNOTATION_NONE
+	})
+	return annotationType
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestRegisterFeature(t *testing.T) {
+	annotationType := registerLandmarks(t)
+	if annotationType != ANNOTATION_MAX || landmark_feature.Type != ANNOTATION_MAX {
+		t.Errorf("Unexpected type %v", annotationType)
+	}
+
+	// A duplicate feature is rejected and left unchanged
+	duplicate := &Feature{Name: "landmark_detection"}
+	if _, err := RegisterFeature(duplicate); err != ErrDuplicateFeature {
+		t.Errorf("Expected ErrDuplicateFeature, got %v", err)
+	} else if duplicate.Type != ANNOTATION_NONE {
+		t.Errorf("Duplicate feature was changed: %v", duplicate.Type)
+	}
+	if _, err := RegisterFeature(&Feature{Name: "LABEL", Type: ANNOTATION_LABEL}); err != ErrDuplicateFeature {
+		t.Errorf("Expected ErrDuplicateFeature, got %v", err)
+	}
+	if _, err := RegisterFeature(&Feature{Name: "TWO", Type: ANNOTATION_MAX<<1 | ANNOTATION_MAX<<2}); err != ErrInvalidFeature {
+		t.Errorf("Expected ErrInvalidFeature, got %v", err)
+	}
+
+	// Features are parsed by name, ignoring case
+	if flags, err := ParseFeatures("label_detection, Landmark_Detection"); err != nil {
+		t.Error(err)
+	} else if flags != ANNOTATION_LABEL|annotationType {
+		t.Errorf("Unexpected flags %v", flags)
+	}
+	if _, err := ParseFeatures("LABEL_DETECTION,NOPE"); err == nil {
+		t.Error("Expected error for unknown feature")
+	}
+
+	// Names are derived from the registered feature names
+	for annotationType, name := range map[AnnotationType]string{
+		ANNOTATION_LABEL:            "ANNOTATION_LABEL_DETECTION",
+		ANNOTATION_SHOT_CHANGE:      "ANNOTATION_SHOT_CHANGE_DETECTION",
+		ANNOTATION_EXPLICIT_CONTENT: "ANNOTATION_EXPLICIT_CONTENT_DETECTION",
+		ANNOTATION_SPEECH:           "ANNOTATION_SPEECH_TRANSCRIPTION",
+		ANNOTATION_LOGO_RECOGNITION: "ANNOTATION_LOGO_RECOGNITION",
+		annotationType:              "ANNOTATION_LANDMARK_DETECTION",
+		ANNOTATION_NONE:             "ANNOTATION_NONE",
+	} {
+		if annotationType.String() != name {
+			t.Errorf("Expected %v, got %v", name, annotationType.String())
+		}
+	}
+}
+
+// TestExtensionFeature checks that a feature which isn't built in is
+// requested, configured and decoded by every API version
+func TestExtensionFeature(t *testing.T) {
+	annotationType := registerLandmarks(t)
+	for _, version := range []Version{VERSION_V1BETA1, VERSION_V1BETA2, VERSION_V1} {
+		var request map[string]interface{}
+		// v1beta1 offsets are in microseconds
+		shot := `{"startTimeOffset":"0s","endTimeOffset":"2s"}`
+		if version == VERSION_V1BETA1 {
+			shot = `{"startTimeOffset":"0","endTimeOffset":"2000000"}`
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				if r.URL.Path != "/"+version.String()+"/videos:annotate" {
+					t.Errorf("%v: Unexpected path %v", version, r.URL.Path)
+				}
+				data, _ := ioutil.ReadAll(r.Body)
+				json.Unmarshal(data, &request)
+				w.Write([]byte(`{"name":"op1"}`))
+			} else {
+				w.Write([]byte(`{"done":true,"response":{"annotationResults":[{"shotAnnotations":[` + shot + `],"landmarkAnnotations":[{"description":"Tower","timeOffset":"1.01s"}]}]}}`))
+			}
+		}))
+		service, err := NewServiceWithClient(server.Client(), WithEndpoint(server.URL), WithVersion(version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, err := service.AnnotateWithConfig("gs://bucket/video.mp4", ANNOTATION_SHOT_CHANGE|annotationType, &Config{Model: "builtin/stable"})
+		if err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+		status, err := service.Status(name)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%v: %v", version, err)
+		}
+
+		// The request has the feature name and video context field
+		if features, _ := request["features"].([]interface{}); len(features) != 2 || features[1] != "LANDMARK_DETECTION" {
+			t.Errorf("%v: Unexpected features %v", version, request["features"])
+		}
+		if videoContext, _ := request["videoContext"].(map[string]interface{}); videoContext == nil || videoContext["landmarkDetectionConfig"] == nil {
+			t.Errorf("%v: Unexpected video context %v", version, request["videoContext"])
+		}
+
+		// The annotations include both built-in and extension annotations
+		if len(status.Annotations.Shots) != 1 {
+			t.Errorf("%v: Unexpected shots %v", version, status.Annotations.Shots)
+		}
+		if landmarks, _ := status.Annotations.Extensions["LANDMARK_DETECTION"].([]*landmark); len(landmarks) != 1 || landmarks[0].Description != "Tower" {
+			t.Errorf("%v: Unexpected extensions %v", version, status.Annotations.Extensions)
+		}
+	}
+}
+
+func TestExtensionJSON(t *testing.T) {
+	registerLandmarks(t)
+	annotations := &Annotations{
+		Shots: []*ShotAnnotation{{0, 2 * time.Second}},
+		Extensions: map[string]interface{}{
+			"LANDMARK_DETECTION": []*landmark{{"Tower", 1010 * time.Millisecond}},
+			"OTHER":              map[string]interface{}{"value": 1.0},
+		},
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(Annotations)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Shots) != 1 || decoded.Shots[0].EndOffset != 2*time.Second {
+		t.Errorf("Unexpected shots %v", decoded.Shots)
+	}
+	if landmarks, ok := decoded.Extensions["LANDMARK_DETECTION"].([]*landmark); ok == false || len(landmarks) != 1 || landmarks[0].Offset != 1010*time.Millisecond {
+		t.Errorf("Unexpected landmarks %#v", decoded.Extensions["LANDMARK_DETECTION"])
+	}
+	if other, ok := decoded.Extensions["OTHER"].(map[string]interface{}); ok == false || other["value"] != 1.0 {
+		t.Errorf("Unexpected extension %#v", decoded.Extensions["OTHER"])
+	}
+}
+
+func TestSnapExtensions(t *testing.T) {
+	registerLandmarks(t)
+	annotations := &Annotations{
+		Shots: []*ShotAnnotation{{10 * time.Millisecond, 1990 * time.Millisecond}},
+		Extensions: map[string]interface{}{
+			"LANDMARK_DETECTION": []*landmark{{"Tower", 1010 * time.Millisecond}},
+			"OTHER":              "unchanged",
+		},
+	}
+	snapped := annotations.Snap(func(offset time.Duration) time.Duration {
+		return offset.Round(100 * time.Millisecond)
+	})
+	if snapped.Shots[0].StartOffset != 0 || snapped.Shots[0].EndOffset != 2*time.Second {
+		t.Errorf("Unexpected shots %v", snapped.Shots)
+	}
+	if landmarks := snapped.Extensions["LANDMARK_DETECTION"].([]*landmark); landmarks[0].Offset != time.Second {
+		t.Errorf("Unexpected landmark offset %v", landmarks[0].Offset)
+	}
+	if snapped.Extensions["OTHER"] != "unchanged" {
+		t.Errorf("Unexpected extension %v", snapped.Extensions["OTHER"])
+	}
+	if annotations.Extensions["LANDMARK_DETECTION"].([]*landmark)[0].Offset != 1010*time.Millisecond {
+		t.Error("The original annotations were changed")
+	}
+}
+
+// TestFeatureFixtures checks that each built-in feature which decodes the
+// operation response is requested, decoded, rendered and snapped, and that
+// snapping doesn't change the original annotations
+func TestFeatureFixtures(t *testing.T) {
+	round := func(offset time.Duration) time.Duration {
+		return offset.Round(100 * time.Millisecond)
+	}
+	for _, feature := range Features() {
+		if feature.Decode == nil {
+			continue
+		}
+		fixture, exists := feature_fixtures[feature.Name]
+		if exists == false {
+			t.Errorf("%v: Missing fixture", feature.Name)
+			continue
+		}
+		status, request := annotateFixture(t, VERSION_V1, feature.Type, nil, fixture.fixture)
+		if features, _ := request["features"].([]interface{}); len(features) != 1 || features[0] != feature.Name {
+			t.Errorf("%v: Unexpected features %v", feature.Name, request["features"])
+		}
+		if status.Done == false {
+			t.Errorf("%v: Expected operation to be done", feature.Name)
+		}
+
+		// Rows are rendered for the annotations
+		rows := feature.Render(status.Annotations)
+		if len(rows) != fixture.rows {
+			t.Errorf("%v: Expected %v rows, got %v", feature.Name, fixture.rows, len(rows))
+			continue
+		}
+
+		// Snapping aligns the offset of each row, without changing the
+		// original annotations
+		if feature.Snap == nil {
+			t.Errorf("%v: Missing Snap hook", feature.Name)
+			continue
+		}
+		snapped := new(Annotations)
+		feature.Snap(snapped, status.Annotations, round)
+		snappedRows := feature.Render(snapped)
+		if len(snappedRows) != len(rows) {
+			t.Errorf("%v: Expected %v snapped rows, got %v", feature.Name, len(rows), len(snappedRows))
+			continue
+		}
+		changed := false
+		for i, row := range rows {
+			if snappedRows[i].Start != round(row.Start) || snappedRows[i].End != round(row.End) {
+				t.Errorf("%v: Row %v: Unexpected offsets %v - %v", feature.Name, i, snappedRows[i].Start, snappedRows[i].End)
+			}
+			changed = changed || snappedRows[i].Start != row.Start || snappedRows[i].End != row.End
+		}
+		if changed == false {
+			t.Errorf("%v: The fixture has no offsets which are snapped", feature.Name)
+		}
+		if reflect.DeepEqual(feature.Render(status.Annotations), rows) == false {
+			t.Errorf("%v: The original annotations were changed", feature.Name)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Ledger records the usage of completed annotation operations in a local
+// file, with one JSON entry per line
+type Ledger struct {
+	path string
+}
+
+// LedgerEntry defines the usage for a single operation. Minutes and cost are
+// keyed by feature name (for example "LABEL_DETECTION")
+type LedgerEntry struct {
+	Time      time.Time          `json:"time"`
+	Operation string             `json:"operation"`
+	Uri       string             `json:"uri"`
+	Features  []string           `json:"features"`
+	Minutes   map[string]int64   `json:"minutes"`
+	Cost      map[string]float64 `json:"cost"`
+	Total     float64            `json:"total"`
+	Currency  string             `json:"currency"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewLedger returns a ledger which is stored in a file, creating the parent
+// folder if necessary
+func NewLedger(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return &Ledger{path}, nil
+}
+
+// Append adds an entry to the ledger
+func (this *Ledger) Append(entry *LedgerEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(this.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Entries returns the entries recorded at or after a time, in the order
+// they were recorded
+func (this *Ledger) Entries(since time.Time) ([]*LedgerEntry, error) {
+	entries := make([]*LedgerEntry, 0)
+	file, err := os.Open(this.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := new(LedgerEntry)
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("%v: line %v: %v", this.path, line, err)
+		}
+		if entry.Time.Before(since) == false {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Spend returns the total cost of entries recorded at or after a time
+func (this *Ledger) Spend(since time.Time) (float64, error) {
+	entries, err := this.Entries(since)
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, entry := range entries {
+		total += entry.Total
+	}
+	return total, nil
+}
+
+// Minutes returns the billable minutes for each feature for entries recorded
+// at or after a time
+func (this *Ledger) Minutes(since time.Time) (map[AnnotationType]int64, error) {
+	entries, err := this.Entries(since)
+	if err != nil {
+		return nil, err
+	}
+	minutes := make(map[AnnotationType]int64)
+	for _, entry := range entries {
+		for name, value := range entry.Minutes {
+			if feature := featureNamed(name); feature != nil {
+				minutes[feature.Type] += value
+			}
+		}
+	}
+	return minutes, nil
+}
+
+// SetLedger sets the ledger in which completed operations are recorded, and
+// the prices and durations used to estimate their cost. Either can be nil
+func (this *Service) SetLedger(ledger *Ledger, config *EstimateConfig) {
+	this.ledger = ledger
+	this.pricing = config
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// recordUsage adds a completed operation to the ledger. When the duration
+// was not known before submission, it is taken from the annotations. When
+// the duration still can't be determined, the operation is only recorded
+// without cost if it produced no annotations, and otherwise isn't recorded
+// and is logged, as the spend would be under-reported to the budget
+func (this *Service) recordUsage(status *Status) error {
+	estimate := status.estimate
+	if estimate == nil {
+		var err error
+		if estimate, err = this.estimateCost(status.Uri, status.flags, status.config, status.Annotations.Duration()); err != nil {
+			if inputErr, ok := err.(*InputError); ok == false || inputErr.Err != ErrUnknownDuration {
+				return err
+			} else if len(status.Annotations.Render()) > 0 {
+				log.Printf("%v: Usage not recorded: %v", status.Uri, ErrUnknownDuration)
+				return nil
+			}
+			estimate = &VideoEstimate{Uri: status.Uri}
+		}
+	}
+	entry := &LedgerEntry{
+		Time:      time.Now(),
+		Operation: status.Name,
+		Uri:       status.Uri,
+		Features:  annotateFlagArray(status.flags),
+		Minutes:   make(map[string]int64, len(estimate.Minutes)),
+		Cost:      make(map[string]float64, len(estimate.Cost)),
+		Total:     estimate.Total,
+		Currency:  this.currency(),
+	}
+	for annotationType, minutes := range estimate.Minutes {
+		feature := annotateFlagArray(annotationType)[0]
+		entry.Minutes[feature] = minutes
+		entry.Cost[feature] = estimate.Cost[annotationType]
+	}
+	return this.ledger.Append(entry)
+}
+
+// estimateCost returns the estimated cost of annotating a single video,
+// taking into account the free minutes already used this month. When the
+// duration is not zero it is used instead of the manifest or local file
+func (this *Service) estimateCost(uri string, flags AnnotationType, config *Config, duration time.Duration) (*VideoEstimate, error) {
+	estimateConfig := &EstimateConfig{Pricing: DefaultPricing}
+	if this.pricing != nil {
+		estimateConfig.Manifest = this.pricing.Manifest
+		if this.pricing.Pricing != nil {
+			estimateConfig.Pricing = this.pricing.Pricing
+		}
+	}
+	if config != nil {
+		estimateConfig.Config = *config
+	}
+	if duration > 0 {
+		estimateConfig.Manifest = map[string]time.Duration{uri: duration}
+	}
+
+	// Reduce the free minutes by those already used this month
+	if this.ledger != nil {
+		used, err := this.ledger.Minutes(startOfMonth(time.Now()))
+		if err != nil {
+			return nil, err
+		}
+		pricing := &Pricing{
+			Currency:    estimateConfig.Pricing.Currency,
+			PerMinute:   estimateConfig.Pricing.PerMinute,
+			FreeMinutes: make(map[AnnotationType]int64, len(estimateConfig.Pricing.FreeMinutes)),
+		}
+		for annotationType, minutes := range estimateConfig.Pricing.FreeMinutes {
+			if minutes > used[annotationType] {
+				pricing.FreeMinutes[annotationType] = minutes - used[annotationType]
+			}
+		}
+		estimateConfig.Pricing = pricing
+	}
+
+	if estimate, err := Estimate([]string{uri}, flags, estimateConfig); err != nil {
+		if errs, ok := err.(InputErrors); ok && len(errs) == 1 {
+			return nil, errs[0]
+		}
+		return nil, err
+	} else {
+		return estimate.Videos[0], nil
+	}
+}
+
+func (this *Service) currency() string {
+	if this.pricing != nil && this.pricing.Pricing != nil {
+		return this.pricing.Pricing.Currency
+	}
+	return DefaultPricing.Currency
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+// TestRecordUsage checks that operations where the duration was not known
+// before submission are recorded using the duration of the annotations
+func TestRecordUsage(t *testing.T) {
+	pricing := &Pricing{
+		Currency:    "USD",
+		PerMinute:   map[AnnotationType]float64{ANNOTATION_SHOT_CHANGE: 1, ANNOTATION_EXPLICIT_CONTENT: 2},
+		FreeMinutes: map[AnnotationType]int64{},
+	}
+	tests := []struct {
+		name        string
+		flags       AnnotationType
+		annotations *Annotations
+		entries     int
+		total       float64
+	}{
+		{"shots", ANNOTATION_SHOT_CHANGE, &Annotations{
+			Shots: []*ShotAnnotation{{StartOffset: 0, EndOffset: 150 * time.Second}},
+		}, 1, 3},
+		{"explicit", ANNOTATION_EXPLICIT_CONTENT, &Annotations{
+			ExplicitContent: []*ExplicitContentAnnotation{{Offset: 59 * time.Second, Likelihood: LIKELIHOOD_LIKELY}},
+		}, 1, 2},
+		{"no annotations", ANNOTATION_SHOT_CHANGE, &Annotations{}, 1, 0},
+		{"unknown duration", ANNOTATION_EXPLICIT_CONTENT, &Annotations{
+			ExplicitContent: []*ExplicitContentAnnotation{{Offset: 0, Likelihood: LIKELIHOOD_LIKELY}},
+		}, 0, 0},
+	}
+	for _, test := range tests {
+		path, err := ioutil.TempDir("", "ledger")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(path)
+		ledger, err := NewLedger(filepath.Join(path, "usage.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		service, err := NewServiceWithClient(http.DefaultClient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.SetLedger(ledger, &EstimateConfig{Pricing: pricing})
+		status := &Status{Name: "op1", Uri: "gs://bucket/video.mp4", flags: test.flags, Annotations: test.annotations}
+		if err := service.recordUsage(status); err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		entries, err := ledger.Entries(time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.entries {
+			t.Errorf("%v: Expected %v entries, got %v", test.name, test.entries, len(entries))
+		} else if len(entries) > 0 && entries[0].Total != test.total {
+			t.Errorf("%v: Expected total %v, got %v", test.name, test.total, entries[0].Total)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// objectResponse decodes object tracking results, which aren't included in
+// the checked-in discovery documents
+type objectResponse struct {
+	AnnotationResults []struct {
+		ObjectAnnotations []*objectAnnotation `json:"objectAnnotations"`
+	} `json:"annotationResults"`
+}
+
+type objectAnnotation struct {
+	Entity *struct {
+		EntityId     string `json:"entityId"`
+		Description  string `json:"description"`
+		LanguageCode string `json:"languageCode"`
+	} `json:"entity"`
+	Confidence float64 `json:"confidence"`
+	Segment    *struct {
+		StartTimeOffset string `json:"startTimeOffset"`
+		EndTimeOffset   string `json:"endTimeOffset"`
+	} `json:"segment"`
+	Frames []*struct {
+		TimeOffset            string `json:"timeOffset"`
+		NormalizedBoundingBox *struct {
+			Left   float64 `json:"left"`
+			Top    float64 `json:"top"`
+			Right  float64 `json:"right"`
+			Bottom float64 `json:"bottom"`
+		} `json:"normalizedBoundingBox"`
+	} `json:"frames"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	object_feature = &Feature{
+		Type:   ANNOTATION_OBJECT_TRACKING,
+		Name:   "OBJECT_TRACKING",
+		Decode: objectTracks,
+		Snap:   snapObjectTracks,
+		Render: func(annotations *Annotations) []*Row {
+			rows := make([]*Row, 0, len(annotations.ObjectTracks))
+			for _, track := range annotations.ObjectTracks {
+				rows = append(rows, &Row{
+					Type:        "object",
+					Entity:      track.Entity.EntityId,
+					Description: track.Entity.Description,
+					Start:       track.StartOffset,
+					End:         track.EndOffset,
+					Confidence:  track.Confidence,
+					Value:       track,
+				})
+			}
+			return rows
+		},
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func snapObjectTracks(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+	snapped.ObjectTracks = make([]*ObjectTrack, len(annotations.ObjectTracks))
+	for i, annotation := range annotations.ObjectTracks {
+		track := *annotation
+		track.StartOffset = snap(annotation.StartOffset)
+		track.EndOffset = snap(annotation.EndOffset)
+		track.Frames = make([]*ObjectFrame, len(annotation.Frames))
+		for j, frame := range annotation.Frames {
+			track.Frames[j] = &ObjectFrame{Offset: snap(frame.Offset), Box: frame.Box}
+		}
+		snapped.ObjectTracks[i] = &track
+	}
+}
+
+// objectTracks appends the tracked objects from an operation response
+func objectTracks(annotations *Annotations, response []byte) error {
+	var results objectResponse
+	if err := json.Unmarshal(response, &results); err != nil {
+		return err
+	}
+	for _, result := range results.AnnotationResults {
+		for _, annotation := range result.ObjectAnnotations {
+			if track, err := objectTrack(annotation); err != nil {
+				return err
+			} else {
+				annotations.ObjectTracks = append(annotations.ObjectTracks, track)
+			}
+		}
+	}
+	return nil
+}
+
+func objectTrack(annotation *objectAnnotation) (*ObjectTrack, error) {
+	var err error
+	track := &ObjectTrack{
+		Entity:     &Entity{},
+		Confidence: annotation.Confidence,
+		Frames:     make([]*ObjectFrame, 0, len(annotation.Frames)),
+	}
+	if annotation.Entity != nil {
+		track.Entity = &Entity{annotation.Entity.EntityId, annotation.Entity.Description, annotation.Entity.LanguageCode}
+	}
+	if annotation.Segment != nil {
+		if track.StartOffset, err = parseOffset(annotation.Segment.StartTimeOffset); err != nil {
+			return nil, err
+		}
+		if track.EndOffset, err = parseOffset(annotation.Segment.EndTimeOffset); err != nil {
+			return nil, err
+		}
+	}
+	for _, frame := range annotation.Frames {
+		offset, err := parseOffset(frame.TimeOffset)
+		if err != nil {
+			return nil, err
+		}
+		box := &BoundingBox{}
+		if frame.NormalizedBoundingBox != nil {
+			box.Left = frame.NormalizedBoundingBox.Left
+			box.Top = frame.NormalizedBoundingBox.Top
+			box.Right = frame.NormalizedBoundingBox.Right
+			box.Bottom = frame.NormalizedBoundingBox.Bottom
+		}
+		track.Frames = append(track.Frames, &ObjectFrame{offset, box})
+	}
+	return track, nil
+}
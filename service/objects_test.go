@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestObjectFrames(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_OBJECT_TRACKING, nil, "objects.json")
+	tracks := status.Annotations.ObjectTracks
+	if len(tracks) != 1 {
+		t.Fatalf("Expected one track, got %v", len(tracks))
+	}
+	track := tracks[0]
+	if track.Entity.EntityId != "/m/0k4j" || track.Confidence != 0.87 {
+		t.Errorf("Unexpected track %v", track)
+	}
+	if len(track.Frames) != 2 || track.Frames[1].Offset != 3400*time.Millisecond || track.Frames[1].Box.Left != 0.15 || track.Frames[1].Box.Bottom != 0.6 {
+		t.Errorf("Unexpected frames %v", track.Frames)
+	}
+}
+
+func TestCheckFeatures(t *testing.T) {
+	for _, version := range []Version{VERSION_V1BETA1, VERSION_V1BETA2} {
+		if err := CheckFeatures(version, ANNOTATION_LABEL|ANNOTATION_OBJECT_TRACKING); err == nil {
+			t.Errorf("%v: Expected error for object tracking", version)
+		}
+		if err := CheckFeatures(version, ANNOTATION_LABEL|ANNOTATION_SHOT_CHANGE|ANNOTATION_EXPLICIT_CONTENT); err != nil {
+			t.Errorf("%v: %v", version, err)
+		}
+	}
+	if err := CheckFeatures(VERSION_V1, registeredTypes()); err != nil {
+		t.Error(err)
+	}
+	if err := CheckFeatures(Version(99), ANNOTATION_LABEL); err != ErrInvalidVersion {
+		t.Errorf("Expected ErrInvalidVersion, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+// TestPollNotModified checks that an unchanged operation is polled with its
+// entity tag, reported as unchanged, and that the full operation is only
+// fetched once it is done
+func TestPollNotModified(t *testing.T) {
+	var polls, notModified, full int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/videos:annotate":
+			w.Write([]byte(`{"name":"op1"}`))
+		case r.URL.Query().Get("fields") == operation_STATUS_FIELDS:
+			polls++
+			if polls == 2 || polls == 3 {
+				if r.Header.Get("If-None-Match") != "e1" {
+					t.Errorf("Poll %v: Unexpected If-None-Match %q", polls, r.Header.Get("If-None-Match"))
+				}
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Etag", "e1")
+			if polls >= 4 {
+				w.Write([]byte(`{"done":true,"metadata":{"annotationProgress":[{"feature":"SHOT_CHANGE_DETECTION","progressPercent":100}]}}`))
+			} else {
+				w.Write([]byte(`{"metadata":{"annotationProgress":[{"feature":"SHOT_CHANGE_DETECTION","progressPercent":40}]}}`))
+			}
+		case r.URL.Query().Get("fields") == "":
+			full++
+			w.Write([]byte(`{"name":"op1","done":true,"response":{"annotationResults":[{"shotAnnotations":[{"startTimeOffset":"0s","endTimeOffset":"2s"}]}]}}`))
+		default:
+			t.Errorf("Unexpected request %v", r.URL)
+		}
+	}))
+	defer server.Close()
+	service, err := NewServiceWithClient(server.Client(), WithEndpoint(server.URL), WithVersion(VERSION_V1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := service.Annotate("gs://bucket/video.mp4", ANNOTATION_SHOT_CHANGE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		status, err := service.Status(name)
+		if err != nil {
+			t.Fatal(err)
+		} else if i < 3 && (status.Done || status.PercentComplete() != 40) {
+			t.Errorf("Poll %v: Unexpected status %v", i, status)
+		} else if i >= 3 && (status.Done == false || len(status.Annotations.Shots) != 1) {
+			t.Errorf("Poll %v: Unexpected status %v", i, status)
+		}
+	}
+	if polls != 4 || notModified != 2 || full != 1 {
+		t.Errorf("Unexpected requests: polls=%v not modified=%v full=%v", polls, notModified, full)
+	}
+}
+
+// TestOperationError checks that a failed operation, or a failed result
+// within an operation, is returned as an OperationError by every version
+func TestOperationError(t *testing.T) {
+	for _, body := range []string{
+		`{"done":true,"error":{"code":3,"message":"Invalid video"}}`,
+		`{"done":true,"response":{"annotationResults":[{"error":{"code":3,"message":"Invalid video"}}]}}`,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.Write([]byte(`{"name":"op1"}`))
+			} else {
+				w.Write([]byte(body))
+			}
+		}))
+		for _, version := range []Version{VERSION_V1BETA1, VERSION_V1BETA2, VERSION_V1} {
+			service, err := NewServiceWithClient(server.Client(), WithEndpoint(server.URL), WithVersion(version))
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := service.Annotate("gs://bucket/video.mp4", ANNOTATION_SHOT_CHANGE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The error is returned again without polling the operation
+			for i := 0; i < 2; i++ {
+				if _, err := service.Status(name); err == nil {
+					t.Errorf("%v: Expected OperationError", version)
+				} else if opError, ok := err.(*OperationError); ok == false || opError.Name != "op1" || opError.Code != 3 || opError.Message != "Invalid video" {
+					t.Errorf("%v: Unexpected error %v", version, err)
+				}
+			}
+		}
+		server.Close()
+	}
+}
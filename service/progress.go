@@ -0,0 +1,273 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// progressMetadata is the operation metadata for all API versions. The
+// feature field isn't in the generated clients
+type progressMetadata struct {
+	AnnotationProgress []*progressDetail `json:"annotationProgress"`
+}
+
+type progressDetail struct {
+	InputUri        string `json:"inputUri"`
+	Feature         string `json:"feature"`
+	ProgressPercent int64  `json:"progressPercent"`
+	StartTime       string `json:"startTime"`
+	UpdateTime      string `json:"updateTime"`
+}
+
+// progressRate estimates the time remaining for an annotation type from
+// samples of the percentage complete over time
+type progressRate struct {
+	samples []*progressSample
+}
+
+type progressSample struct {
+	time    time.Time
+	percent float64
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Number of recent samples used to estimate the rate of progress
+	rate_SAMPLES = 20
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// SetProgress sets the progress for an annotation type, and adds a sample
+// for estimating the time remaining
+func (this *Status) SetProgress(annotationType AnnotationType, progress *Progress) {
+	if this.Progress == nil {
+		this.Progress = make(map[AnnotationType]*Progress, len(this.Type))
+	}
+	if this.rates == nil {
+		this.rates = make(map[AnnotationType]*progressRate, len(this.Type))
+	}
+	rate, exists := this.rates[annotationType]
+	if exists == false {
+		rate = new(progressRate)
+		this.rates[annotationType] = rate
+		// The start of processing is the first sample
+		if progress.StartTime.IsZero() == false && progress.Percent < 100 {
+			rate.add(progress.StartTime, 0)
+		}
+	}
+	when := progress.UpdateTime
+	if when.IsZero() {
+		when = time.Now()
+	}
+	rate.add(when, float64(progress.Percent))
+	this.Progress[annotationType] = progress
+}
+
+// PercentComplete returns the percentage completion of the operation, where
+// annotation types without progress are not yet started
+func (this *Status) PercentComplete() float64 {
+	if this.Done {
+		return 100
+	}
+	types := this.Type
+	if len(types) == 0 {
+		for annotationType := range this.Progress {
+			types = append(types, annotationType)
+		}
+	}
+	if len(types) == 0 {
+		return 0
+	}
+	var percent float64
+	for _, annotationType := range types {
+		if progress, exists := this.Progress[annotationType]; exists {
+			percent += float64(progress.Percent)
+		}
+	}
+	return percent / float64(len(types))
+}
+
+// RemainingFor returns the estimated time remaining for an annotation type
+// at the last progress update, or false if it can't yet be estimated
+func (this *Status) RemainingFor(annotationType AnnotationType) (time.Duration, bool) {
+	if this.Done {
+		return 0, true
+	} else if progress, exists := this.Progress[annotationType]; exists && progress.Done {
+		return 0, true
+	} else if rate, exists := this.rates[annotationType]; exists == false {
+		return 0, false
+	} else {
+		return rate.remaining()
+	}
+}
+
+// Remaining returns the estimated time remaining for the operation, which
+// is the longest time remaining for any annotation type since they are
+// processed in parallel, or false if it can't yet be estimated
+func (this *Status) Remaining() (time.Duration, bool) {
+	if this.Done {
+		return 0, true
+	} else if len(this.Type) == 0 {
+		return 0, false
+	}
+	var remaining time.Duration
+	for _, annotationType := range this.Type {
+		if value, ok := this.RemainingFor(annotationType); ok == false {
+			return 0, false
+		} else if value > remaining {
+			remaining = value
+		}
+	}
+	return remaining, true
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// decodeProgress returns the progress for each requested annotation type
+// from operation metadata. Progress is matched by feature name and input
+// URI, and otherwise by position for API versions which don't return the
+// feature. Several entries for the same annotation type are combined, and
+// there is no progress when no entry is for the input URI
+func decodeProgress(metadata []byte, uri string, types []AnnotationType) (map[AnnotationType]*Progress, error) {
+	progress := make(map[AnnotationType]*Progress, len(types))
+	if len(metadata) == 0 {
+		return progress, nil
+	}
+	var value progressMetadata
+	if err := json.Unmarshal(metadata, &value); err != nil {
+		return nil, err
+	}
+
+	// Use the entries for the input
+	details := make([]*progressDetail, 0, len(value.AnnotationProgress))
+	for _, detail := range value.AnnotationProgress {
+		if detail != nil && matchInputUri(detail.InputUri, uri) {
+			details = append(details, detail)
+		}
+	}
+
+	// Match entries by feature, then the others by position. A single
+	// entry without a feature is the progress for all annotation types
+	matched := make(map[AnnotationType][]*progressDetail, len(types))
+	other := make([]*progressDetail, 0, len(details))
+	for _, detail := range details {
+		if detail.Feature == "" || detail.Feature == "FEATURE_UNSPECIFIED" {
+			other = append(other, detail)
+		} else if annotationType := progressType(detail.Feature); containsType(types, annotationType) {
+			matched[annotationType] = append(matched[annotationType], detail)
+		}
+	}
+	for i, annotationType := range types {
+		if len(matched[annotationType]) > 0 {
+			continue
+		} else if len(other) == 1 {
+			matched[annotationType] = other
+		} else if i < len(other) {
+			matched[annotationType] = other[i : i+1]
+		}
+	}
+
+	// Combine the entries for each annotation type
+	for annotationType, details := range matched {
+		progress[annotationType] = combineProgress(details)
+	}
+	return progress, nil
+}
+
+// combineProgress returns the average progress of several entries, with
+// the earliest start time and the latest update time
+func combineProgress(details []*progressDetail) *Progress {
+	var percent int64
+	combined := &Progress{}
+	for _, detail := range details {
+		progress := newProgress(detail.ProgressPercent, detail.StartTime, detail.UpdateTime)
+		percent += progress.Percent
+		if combined.StartTime.IsZero() || (progress.StartTime.IsZero() == false && progress.StartTime.Before(combined.StartTime)) {
+			combined.StartTime = progress.StartTime
+		}
+		if progress.UpdateTime.After(combined.UpdateTime) {
+			combined.UpdateTime = progress.UpdateTime
+		}
+	}
+	combined.Percent = percent / int64(len(details))
+	combined.Done = combined.Percent == 100
+	return combined
+}
+
+// matchInputUri returns true if the input URI in operation metadata, which
+// is a path such as /bucket/object, refers to a URI. The input URI is empty
+// for local files
+func matchInputUri(inputUri, uri string) bool {
+	if inputUri == "" || inputUri == uri {
+		return true
+	}
+	if IsCloudStorageUri(uri) {
+		return strings.TrimPrefix(inputUri, "/") == strings.TrimPrefix(uri, cloud_STORAGE_SCHEME)
+	}
+	return false
+}
+
+// progressType returns the annotation type for a feature name, including
+// the names used by v1beta1, or ANNOTATION_NONE
+func progressType(name string) AnnotationType {
+	if feature := featureNamed(name); feature != nil {
+		return feature.Type
+	}
+	for annotationType, value := range v1beta1_feature_map {
+		if strings.EqualFold(value, name) {
+			return annotationType
+		}
+	}
+	return ANNOTATION_NONE
+}
+
+func containsType(types []AnnotationType, annotationType AnnotationType) bool {
+	for _, value := range types {
+		if value == annotationType {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends a sample, ignoring samples which are not later than the last
+// and discarding earlier samples if progress goes backwards
+func (this *progressRate) add(when time.Time, percent float64) {
+	if n := len(this.samples); n > 0 {
+		last := this.samples[n-1]
+		if when.After(last.time) == false {
+			return
+		} else if percent < last.percent {
+			this.samples = this.samples[:0]
+		}
+	}
+	this.samples = append(this.samples, &progressSample{when, percent})
+	if len(this.samples) > rate_SAMPLES {
+		this.samples = this.samples[len(this.samples)-rate_SAMPLES:]
+	}
+}
+
+// remaining returns the time to complete at the rate of progress across
+// the samples, or false if there is no progress yet
+func (this *progressRate) remaining() (time.Duration, bool) {
+	if len(this.samples) < 2 {
+		return 0, false
+	}
+	first, last := this.samples[0], this.samples[len(this.samples)-1]
+	if last.percent >= 100 {
+		return 0, true
+	} else if last.percent <= first.percent {
+		return 0, false
+	}
+	perSecond := (last.percent - first.percent) / last.time.Sub(first.time).Seconds()
+	return time.Duration((100 - last.percent) / perSecond * float64(time.Second)), true
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestDecodeProgress(t *testing.T) {
+	types := []AnnotationType{ANNOTATION_LABEL, ANNOTATION_SHOT_CHANGE}
+
+	// Entries are matched by feature and input URI
+	data := []byte(`{"annotationProgress":[
+		{"inputUri":"/bucket/a.mp4","feature":"SHOT_CHANGE_DETECTION","progressPercent":40},
+		{"inputUri":"/bucket/b.mp4","feature":"LABEL_DETECTION","progressPercent":90},
+		{"inputUri":"/bucket/a.mp4","feature":"LABEL_DETECTION","progressPercent":10}]}`)
+	if progress, err := decodeProgress(data, "gs://bucket/a.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if len(progress) != 2 || progress[ANNOTATION_LABEL].Percent != 10 || progress[ANNOTATION_SHOT_CHANGE].Percent != 40 {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+
+	// There is no progress when no entry is for the input URI
+	if progress, err := decodeProgress(data, "gs://bucket/c.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if len(progress) != 0 {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+
+	// Entries without a feature are matched by position
+	data = []byte(`{"annotationProgress":[{"progressPercent":5},{"progressPercent":100}]}`)
+	if progress, err := decodeProgress(data, "video.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if progress[ANNOTATION_LABEL].Percent != 5 || progress[ANNOTATION_SHOT_CHANGE].Done == false {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+
+	// A single entry without a feature is for all annotation types
+	data = []byte(`{"annotationProgress":[{"inputUri":"/bucket/a.mp4","progressPercent":30}]}`)
+	if progress, err := decodeProgress(data, "gs://bucket/a.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if len(progress) != 2 || progress[ANNOTATION_LABEL].Percent != 30 || progress[ANNOTATION_SHOT_CHANGE].Percent != 30 {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+
+	// Several entries for an annotation type are combined
+	data = []byte(`{"annotationProgress":[
+		{"inputUri":"/bucket/a.mp4","feature":"LABEL_DETECTION","progressPercent":20,"startTime":"2020-01-01T00:00:10Z","updateTime":"2020-01-01T00:01:00Z"},
+		{"inputUri":"/bucket/a.mp4","feature":"LABEL_DETECTION","progressPercent":40,"startTime":"2020-01-01T00:00:00Z","updateTime":"2020-01-01T00:00:30Z"}]}`)
+	if progress, err := decodeProgress(data, "gs://bucket/a.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if label := progress[ANNOTATION_LABEL]; label.Percent != 30 || label.StartTime.Second() != 0 || label.UpdateTime.Minute() != 1 {
+		t.Errorf("Unexpected progress %v", label)
+	}
+
+	if progress, err := decodeProgress(nil, "gs://bucket/a.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if len(progress) != 0 {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+}
+
+func TestDecodeProgressV1beta1(t *testing.T) {
+	// v1beta1 names explicit content detection SAFE_SEARCH_DETECTION
+	types := []AnnotationType{ANNOTATION_EXPLICIT_CONTENT, ANNOTATION_LABEL}
+	data := []byte(`{"annotationProgress":[
+		{"inputUri":"/bucket/a.mp4","feature":"LABEL_DETECTION","progressPercent":70},
+		{"inputUri":"/bucket/a.mp4","feature":"SAFE_SEARCH_DETECTION","progressPercent":20}]}`)
+	if progress, err := decodeProgress(data, "gs://bucket/a.mp4", types); err != nil {
+		t.Fatal(err)
+	} else if progress[ANNOTATION_EXPLICIT_CONTENT].Percent != 20 || progress[ANNOTATION_LABEL].Percent != 70 {
+		t.Errorf("Unexpected progress %v", progress)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	status := &Status{Type: []AnnotationType{ANNOTATION_LABEL, ANNOTATION_SHOT_CHANGE}}
+	if percent := status.PercentComplete(); percent != 0 {
+		t.Errorf("Unexpected percent %v", percent)
+	}
+	if _, ok := status.Remaining(); ok {
+		t.Error("Expected no estimate without progress")
+	}
+	start := time.Now()
+	status.SetProgress(ANNOTATION_LABEL, &Progress{Percent: 50, StartTime: start, UpdateTime: start.Add(50 * time.Second)})
+	if percent := status.PercentComplete(); percent != 25 {
+		t.Errorf("Unexpected percent %v", percent)
+	}
+	if remaining, ok := status.RemainingFor(ANNOTATION_LABEL); ok == false || remaining != 50*time.Second {
+		t.Errorf("Unexpected remaining %v", remaining)
+	}
+	if _, ok := status.Remaining(); ok {
+		t.Error("Expected no estimate without progress for every type")
+	}
+	status.SetProgress(ANNOTATION_SHOT_CHANGE, &Progress{Percent: 100, Done: true, StartTime: start, UpdateTime: start.Add(10 * time.Second)})
+	status.SetProgress(ANNOTATION_LABEL, &Progress{Percent: 60, StartTime: start, UpdateTime: start.Add(60 * time.Second)})
+	if remaining, ok := status.Remaining(); ok == false || remaining != 40*time.Second {
+		t.Errorf("Unexpected remaining %v", remaining)
+	}
+	var empty Status
+	if percent := empty.PercentComplete(); percent != 0 {
+		t.Errorf("Unexpected percent %v", percent)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TEST SERVER
+
+// annotateFixture annotates a video with a local server, which completes
+// the operation with the response in a file in testdata. Returns the status
+// and the request body
+func annotateFixture(t *testing.T, version Version, flags AnnotationType, config *Config, fixture string) (*Status, map[string]interface{}) {
+	t.Helper()
+	response, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := make(map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			data, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(data, &request); err != nil {
+				t.Error(err)
+			}
+			w.Write([]byte(`{"name":"op1"}`))
+		} else {
+			w.Write([]byte(`{"name":"op1","done":true,"response":`))
+			w.Write(response)
+			w.Write([]byte(`}`))
+		}
+	}))
+	defer server.Close()
+	service, err := NewServiceWithClient(server.Client(), WithEndpoint(server.URL), WithVersion(version))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := service.AnnotateWithConfig("gs://bucket/video.mp4", flags, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := service.Status(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status, request
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// speechResponse decodes speech transcription results, which aren't
+// included in the checked-in discovery documents
+type speechResponse struct {
+	AnnotationResults []struct {
+		SpeechTranscriptions []*speechTranscription `json:"speechTranscriptions"`
+	} `json:"annotationResults"`
+}
+
+type speechTranscription struct {
+	LanguageCode string `json:"languageCode"`
+	Alternatives []*struct {
+		Transcript string  `json:"transcript"`
+		Confidence float64 `json:"confidence"`
+		Words      []*struct {
+			StartTime  string  `json:"startTime"`
+			EndTime    string  `json:"endTime"`
+			Word       string  `json:"word"`
+			Confidence float64 `json:"confidence"`
+			SpeakerTag int64   `json:"speakerTag"`
+		} `json:"words"`
+	} `json:"alternatives"`
+}
+
+type v1SpeechTranscriptionConfig struct {
+	LanguageCode               string             `json:"languageCode"`
+	MaxAlternatives            int64              `json:"maxAlternatives,omitempty"`
+	FilterProfanity            bool               `json:"filterProfanity,omitempty"`
+	SpeechContexts             []*v1SpeechContext `json:"speechContexts,omitempty"`
+	EnableAutomaticPunctuation bool               `json:"enableAutomaticPunctuation,omitempty"`
+	EnableSpeakerDiarization   bool               `json:"enableSpeakerDiarization,omitempty"`
+	DiarizationSpeakerCount    int64              `json:"diarizationSpeakerCount,omitempty"`
+}
+
+type v1SpeechContext struct {
+	Phrases []string `json:"phrases"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	// Speech transcription always needs a video context for the language
+	speech_feature = &Feature{
+		Type: ANNOTATION_SPEECH,
+		Name: "SPEECH_TRANSCRIPTION",
+		Context: func(config *Config) (string, interface{}) {
+			if config == nil {
+				return "speechTranscriptionConfig", v1SpeechConfigFor(nil)
+			}
+			return "speechTranscriptionConfig", v1SpeechConfigFor(config.Speech)
+		},
+		Decode: speechTranscriptions,
+		Render: renderSpeech,
+		Snap:   snapSpeech,
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// Words returns the words of the most likely alternative of each
+// transcription, in time order. With speaker diarization, the last
+// transcription repeats every word with a speaker, in which case only
+// the words with a speaker are returned
+func (this *Annotations) Words() []*Word {
+	words := make([]*Word, 0)
+	speakers := make([]*Word, 0)
+	for _, transcription := range this.Speech {
+		if len(transcription.Alternatives) == 0 {
+			continue
+		}
+		for _, word := range transcription.Alternatives[0].Words {
+			if word.Speaker > 0 {
+				speakers = append(speakers, word)
+			} else {
+				words = append(words, word)
+			}
+		}
+	}
+	if len(speakers) > 0 {
+		words = speakers
+	}
+	sort.SliceStable(words, func(i, j int) bool {
+		return words[i].StartOffset < words[j].StartOffset
+	})
+	return words
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// speechTranscriptions appends the transcriptions from an operation response
+func speechTranscriptions(annotations *Annotations, response []byte) error {
+	var results speechResponse
+	if err := json.Unmarshal(response, &results); err != nil {
+		return err
+	}
+	for _, result := range results.AnnotationResults {
+		for _, transcription := range result.SpeechTranscriptions {
+			speech := &SpeechTranscription{
+				LanguageCode: transcription.LanguageCode,
+				Alternatives: make([]*SpeechAlternative, 0, len(transcription.Alternatives)),
+			}
+			for _, alternative := range transcription.Alternatives {
+				speechAlternative := &SpeechAlternative{
+					Transcript: alternative.Transcript,
+					Confidence: alternative.Confidence,
+					Words:      make([]*Word, 0, len(alternative.Words)),
+				}
+				for _, word := range alternative.Words {
+					start, err := parseOffset(word.StartTime)
+					if err != nil {
+						return err
+					}
+					end, err := parseOffset(word.EndTime)
+					if err != nil {
+						return err
+					}
+					speechAlternative.Words = append(speechAlternative.Words, &Word{
+						StartOffset: start,
+						EndOffset:   end,
+						Word:        word.Word,
+						Confidence:  word.Confidence,
+						Speaker:     word.SpeakerTag,
+					})
+				}
+				speech.Alternatives = append(speech.Alternatives, speechAlternative)
+			}
+			annotations.Speech = append(annotations.Speech, speech)
+		}
+	}
+	return nil
+}
+
+// renderSpeech adds a row for the most likely transcript of each section
+// of speech
+func renderSpeech(annotations *Annotations) []*Row {
+	rows := make([]*Row, 0, len(annotations.Speech))
+	for _, transcription := range annotations.Speech {
+		if len(transcription.Alternatives) == 0 || len(transcription.Alternatives[0].Words) == 0 {
+			continue
+		}
+		alternative := transcription.Alternatives[0]
+		rows = append(rows, &Row{
+			Type:        "speech",
+			Entity:      transcription.LanguageCode,
+			Description: strings.TrimSpace(alternative.Transcript),
+			Start:       alternative.Words[0].StartOffset,
+			End:         alternative.Words[len(alternative.Words)-1].EndOffset,
+			Confidence:  alternative.Confidence,
+			Value:       transcription,
+		})
+	}
+	return rows
+}
+
+func snapSpeech(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+	snapped.Speech = make([]*SpeechTranscription, len(annotations.Speech))
+	for i, annotation := range annotations.Speech {
+		speech := &SpeechTranscription{LanguageCode: annotation.LanguageCode, Alternatives: make([]*SpeechAlternative, len(annotation.Alternatives))}
+		for j, alternative := range annotation.Alternatives {
+			snappedAlternative := *alternative
+			snappedAlternative.Words = make([]*Word, len(alternative.Words))
+			for k, word := range alternative.Words {
+				snappedWord := *word
+				snappedWord.StartOffset = snap(word.StartOffset)
+				snappedWord.EndOffset = snap(word.EndOffset)
+				snappedAlternative.Words[k] = &snappedWord
+			}
+			speech.Alternatives[j] = &snappedAlternative
+		}
+		snapped.Speech[i] = speech
+	}
+}
+
+// v1SpeechConfigFor returns the speech transcription config for a request,
+// which needs a language code even when there is no configuration
+func v1SpeechConfigFor(config *SpeechConfig) *v1SpeechTranscriptionConfig {
+	if config == nil {
+		return &v1SpeechTranscriptionConfig{LanguageCode: speech_LANGUAGE_CODE}
+	}
+	speech := &v1SpeechTranscriptionConfig{
+		LanguageCode:               config.LanguageCode,
+		MaxAlternatives:            config.MaxAlternatives,
+		FilterProfanity:            config.FilterProfanity,
+		EnableAutomaticPunctuation: config.Punctuation,
+		EnableSpeakerDiarization:   config.Speakers > 0,
+		DiarizationSpeakerCount:    config.Speakers,
+	}
+	if speech.LanguageCode == "" {
+		speech.LanguageCode = speech_LANGUAGE_CODE
+	}
+	if len(config.Phrases) > 0 {
+		speech.SpeechContexts = []*v1SpeechContext{{Phrases: config.Phrases}}
+	}
+	return speech
+}
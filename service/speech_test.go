@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestSpeechConfig(t *testing.T) {
+	config := &Config{Speech: &SpeechConfig{LanguageCode: "fr-FR", Speakers: 2, Punctuation: true, Phrases: []string{"Thorpe"}}}
+	_, request := annotateFixture(t, VERSION_V1, ANNOTATION_SPEECH, config, "speech.json")
+	videoContext, _ := request["videoContext"].(map[string]interface{})
+	if speechConfig, _ := videoContext["speechTranscriptionConfig"].(map[string]interface{}); speechConfig == nil {
+		t.Errorf("Missing speech transcription config in %v", videoContext)
+	} else if speechConfig["languageCode"] != "fr-FR" || speechConfig["enableSpeakerDiarization"] != true || speechConfig["diarizationSpeakerCount"] != 2.0 || speechConfig["enableAutomaticPunctuation"] != true || speechConfig["speechContexts"] == nil {
+		t.Errorf("Unexpected speech transcription config %v", speechConfig)
+	}
+}
+
+func TestSpeechDefaultLanguage(t *testing.T) {
+	_, request := annotateFixture(t, VERSION_V1, ANNOTATION_SPEECH, nil, "speech.json")
+	videoContext, _ := request["videoContext"].(map[string]interface{})
+	if speechConfig, _ := videoContext["speechTranscriptionConfig"].(map[string]interface{}); len(speechConfig) != 1 || speechConfig["languageCode"] != speech_LANGUAGE_CODE {
+		t.Errorf("Unexpected speech transcription config %v", speechConfig)
+	}
+}
+
+func TestWords(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_SPEECH, nil, "speech.json")
+
+	// With speaker diarization, only the words with a speaker are returned
+	words := status.Annotations.Words()
+	if len(words) != 3 {
+		t.Fatalf("Expected three words, got %v", len(words))
+	}
+	for i, speaker := range []int64{1, 1, 2} {
+		if words[i].Speaker != speaker {
+			t.Errorf("Word %v: Expected speaker %v, got %v", i, speaker, words[i].Speaker)
+		}
+	}
+	if words[2].Word != "hi" || words[2].StartOffset != time.Second {
+		t.Errorf("Unexpected word %v", words[2])
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE STRUCTS
+
+// textResponse decodes text detection results, which aren't included in
+// the checked-in discovery documents
+type textResponse struct {
+	AnnotationResults []struct {
+		TextAnnotations []*textAnnotation `json:"textAnnotations"`
+	} `json:"annotationResults"`
+}
+
+type textAnnotation struct {
+	Text     string `json:"text"`
+	Segments []*struct {
+		Segment *struct {
+			StartTimeOffset string `json:"startTimeOffset"`
+			EndTimeOffset   string `json:"endTimeOffset"`
+		} `json:"segment"`
+		Confidence float64 `json:"confidence"`
+		Frames     []*struct {
+			TimeOffset         string `json:"timeOffset"`
+			RotatedBoundingBox *struct {
+				Vertices []*struct {
+					X float64 `json:"x"`
+					Y float64 `json:"y"`
+				} `json:"vertices"`
+			} `json:"rotatedBoundingBox"`
+		} `json:"frames"`
+	} `json:"segments"`
+}
+
+type v1TextDetectionConfig struct {
+	LanguageHints []string `json:"languageHints,omitempty"`
+	Model         string   `json:"model,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	text_feature = &Feature{
+		Type: ANNOTATION_TEXT_DETECTION,
+		Name: "TEXT_DETECTION",
+		Context: func(config *Config) (string, interface{}) {
+			if config == nil || (len(config.TextLanguageHints) == 0 && config.Model == "") {
+				return "", nil
+			}
+			return "textDetectionConfig", &v1TextDetectionConfig{
+				LanguageHints: config.TextLanguageHints,
+				Model:         config.Model,
+			}
+		},
+		Decode: textAnnotations,
+		Render: func(annotations *Annotations) []*Row {
+			rows := make([]*Row, 0, len(annotations.Text))
+			for _, text := range annotations.Text {
+				for _, segment := range text.Segments {
+					rows = append(rows, &Row{
+						Type:        "text",
+						Description: text.Text,
+						Start:       segment.StartOffset,
+						End:         segment.EndOffset,
+						Confidence:  segment.Confidence,
+						Value:       text,
+					})
+				}
+			}
+			return rows
+		},
+		Snap: snapText,
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// SearchText returns the detected text which contains the query, ignoring
+// case and differences in whitespace
+func (this *Annotations) SearchText(query string) []*TextAnnotation {
+	query = normaliseText(query)
+	matches := make([]*TextAnnotation, 0)
+	for _, text := range this.Text {
+		if strings.Contains(normaliseText(text.Text), query) {
+			matches = append(matches, text)
+		}
+	}
+	return matches
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// textAnnotations appends the detected text from an operation response
+func textAnnotations(annotations *Annotations, response []byte) error {
+	var results textResponse
+	if err := json.Unmarshal(response, &results); err != nil {
+		return err
+	}
+	for _, result := range results.AnnotationResults {
+		for _, annotation := range result.TextAnnotations {
+			text := &TextAnnotation{
+				Text:     annotation.Text,
+				Segments: make([]*TextSegment, 0, len(annotation.Segments)),
+			}
+			for _, segment := range annotation.Segments {
+				textSegment := &TextSegment{
+					Confidence: segment.Confidence,
+					Frames:     make([]*TextFrame, 0, len(segment.Frames)),
+				}
+				if segment.Segment != nil {
+					var err error
+					if textSegment.StartOffset, err = parseOffset(segment.Segment.StartTimeOffset); err != nil {
+						return err
+					}
+					if textSegment.EndOffset, err = parseOffset(segment.Segment.EndTimeOffset); err != nil {
+						return err
+					}
+				}
+				for _, frame := range segment.Frames {
+					offset, err := parseOffset(frame.TimeOffset)
+					if err != nil {
+						return err
+					}
+					textFrame := &TextFrame{Offset: offset, Polygon: make([]*Vertex, 0, 4)}
+					if frame.RotatedBoundingBox != nil {
+						for _, vertex := range frame.RotatedBoundingBox.Vertices {
+							textFrame.Polygon = append(textFrame.Polygon, &Vertex{vertex.X, vertex.Y})
+						}
+					}
+					textSegment.Frames = append(textSegment.Frames, textFrame)
+				}
+				text.Segments = append(text.Segments, textSegment)
+			}
+			annotations.Text = append(annotations.Text, text)
+		}
+	}
+	return nil
+}
+
+func snapText(snapped, annotations *Annotations, snap func(time.Duration) time.Duration) {
+	snapped.Text = make([]*TextAnnotation, len(annotations.Text))
+	for i, annotation := range annotations.Text {
+		text := &TextAnnotation{Text: annotation.Text, Segments: make([]*TextSegment, len(annotation.Segments))}
+		for j, segment := range annotation.Segments {
+			snappedSegment := *segment
+			snappedSegment.StartOffset = snap(segment.StartOffset)
+			snappedSegment.EndOffset = snap(segment.EndOffset)
+			snappedSegment.Frames = make([]*TextFrame, len(segment.Frames))
+			for k, frame := range segment.Frames {
+				snappedSegment.Frames[k] = &TextFrame{Offset: snap(frame.Offset), Polygon: frame.Polygon}
+			}
+			text.Segments[j] = &snappedSegment
+		}
+		snapped.Text[i] = text
+	}
+}
+
+// normaliseText returns text in lower case with runs of whitespace replaced
+// by a single space
+func normaliseText(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
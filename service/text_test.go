@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestTextLanguageHints(t *testing.T) {
+	config := &Config{TextLanguageHints: []string{"en-US"}, Model: "builtin/latest"}
+	_, request := annotateFixture(t, VERSION_V1, ANNOTATION_TEXT_DETECTION, config, "text.json")
+	videoContext, _ := request["videoContext"].(map[string]interface{})
+	if textConfig, _ := videoContext["textDetectionConfig"].(map[string]interface{}); textConfig == nil {
+		t.Errorf("Missing text detection config in %v", videoContext)
+	} else if hints, _ := textConfig["languageHints"].([]interface{}); len(hints) != 1 || hints[0] != "en-US" || textConfig["model"] != "builtin/latest" {
+		t.Errorf("Unexpected text detection config %v", textConfig)
+	}
+}
+
+func TestTextPolygon(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_TEXT_DETECTION, nil, "text.json")
+	text := status.Annotations.Text
+	if len(text) != 2 {
+		t.Fatalf("Expected two annotations, got %v", len(text))
+	}
+	if frames := text[0].Segments[0].Frames; len(frames) != 2 || len(frames[0].Polygon) != 4 || frames[0].Polygon[2].X != 0.6 || frames[0].Polygon[2].Y != 0.9 {
+		t.Errorf("Unexpected frames %v", frames)
+	}
+	// A segment without frames has no polygons
+	if len(text[1].Segments[0].Frames) != 0 {
+		t.Errorf("Unexpected frames %v", text[1].Segments[0].Frames)
+	}
+}
+
+func TestSearchText(t *testing.T) {
+	status, _ := annotateFixture(t, VERSION_V1, ANNOTATION_TEXT_DETECTION, nil, "text.json")
+	if matches := status.Annotations.SearchText("breaking news"); len(matches) != 1 || matches[0].Text != "BREAKING  News" {
+		t.Errorf("Unexpected matches %v", matches)
+	}
+	if matches := status.Annotations.SearchText(" SLATE "); len(matches) != 1 {
+		t.Errorf("Unexpected matches %v", matches)
+	}
+	if matches := status.Annotations.SearchText("weather"); len(matches) != 0 {
+		t.Errorf("Unexpected matches %v", matches)
+	}
+}
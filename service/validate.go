@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/djthorpe/VideoIntelligence/probe"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// InputError defines an error for a single input
+type InputError struct {
+	Uri string
+	Err error
+}
+
+// InputErrors defines errors for several inputs
+type InputErrors []*InputError
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+const (
+	// Maximum size of a local file which is sent with the request
+	content_MAX_SIZE = 10 * 1024 * 1024
+	// Maximum length of an object name in bytes
+	object_MAX_LENGTH = 1024
+)
+
+var (
+	ErrInvalidUri          = errors.New("Invalid Cloud Storage URI")
+	ErrInvalidBucket       = errors.New("Invalid bucket name")
+	ErrInvalidObject       = errors.New("Invalid object name")
+	ErrInvalidWildcard     = errors.New("Wildcards are only supported in object names")
+	ErrUnsupportedFormat   = errors.New("Unsupported video format")
+	ErrContentTooLarge     = errors.New("File too large to send with the request, upload to Cloud Storage instead")
+	ErrEmptyContent        = errors.New("Empty file")
+	ErrNotRegularFile      = errors.New("Not a regular file")
+	ErrWildcardLocalFile   = errors.New("Wildcards are not supported for local files")
+	ErrInvalidMovieContent = errors.New("Invalid movie container")
+)
+
+var (
+	// Bucket names are lowercase letters, numbers, dashes, underscores and
+	// dots, and start and end with a letter or number
+	bucket_regexp = regexp.MustCompile("^[a-z0-9][a-z0-9._-]{1,220}[a-z0-9]$")
+	// Supported container formats for local files
+	supported_formats = map[probe.Format]bool{
+		probe.FORMAT_MP4:       true,
+		probe.FORMAT_QUICKTIME: true,
+		probe.FORMAT_AVI:       true,
+		probe.FORMAT_MATROSKA:  true,
+		probe.FORMAT_WEBM:      true,
+		probe.FORMAT_MPEGTS:    true,
+		probe.FORMAT_FLV:       true,
+	}
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// ValidateInput checks a Cloud Storage URI or local file before it is
+// submitted. Cloud Storage URIs are checked for syntax, and local files are
+// checked for size and container format. Returns an *InputError on failure
+func ValidateInput(uri string) error {
+	var err error
+	if IsCloudStorageUri(uri) {
+		err = validateCloudStorageUri(uri)
+	} else {
+		err = validateLocalFile(uri)
+	}
+	if err != nil {
+		return &InputError{uri, err}
+	}
+	return nil
+}
+
+// ValidateInputs checks all the inputs, and returns InputErrors for
+// every input which fails, or nil
+func ValidateInputs(uris []string) error {
+	errs := make(InputErrors, 0)
+	for _, uri := range uris {
+		if err := ValidateInput(uri); err != nil {
+			errs = append(errs, err.(*InputError))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+func validateCloudStorageUri(uri string) error {
+	path := strings.TrimPrefix(uri, cloud_STORAGE_SCHEME)
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 {
+		return ErrInvalidUri
+	}
+	bucket, object := parts[0], parts[1]
+	if strings.ContainsAny(bucket, "*?") {
+		return ErrInvalidWildcard
+	}
+	if bucket_regexp.MatchString(bucket) == false || strings.Contains(bucket, "..") {
+		return ErrInvalidBucket
+	}
+	if len(bucket) > 63 && strings.Contains(bucket, ".") == false {
+		// Only bucket names with dots can be longer than 63 characters
+		return ErrInvalidBucket
+	}
+	for _, component := range strings.Split(bucket, ".") {
+		if len(component) == 0 || len(component) > 63 {
+			return ErrInvalidBucket
+		}
+	}
+	if object == "" || len(object) > object_MAX_LENGTH || utf8.ValidString(object) == false {
+		return ErrInvalidObject
+	}
+	if strings.ContainsAny(object, "\r\n") || object == "." || object == ".." {
+		return ErrInvalidObject
+	}
+	return nil
+}
+
+func validateLocalFile(filename string) error {
+	if strings.ContainsAny(filename, "*?") {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return ErrWildcardLocalFile
+		}
+	}
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if stat.Mode().IsRegular() == false {
+		return ErrNotRegularFile
+	}
+	if stat.Size() == 0 {
+		return ErrEmptyContent
+	}
+	if stat.Size() > content_MAX_SIZE {
+		return ErrContentTooLarge
+	}
+	format, err := probe.SniffFile(filename)
+	if err != nil {
+		return err
+	}
+	if supported_formats[format] == false {
+		return ErrUnsupportedFormat
+	}
+	if format == probe.FORMAT_MP4 || format == probe.FORMAT_QUICKTIME {
+		// Check the movie header can be read
+		if _, err := probe.Open(filename); err != nil {
+			return fmt.Errorf("%v: %v", ErrInvalidMovieContent, err)
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Uri, e.Err)
+}
+
+func (e InputErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
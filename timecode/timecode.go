@@ -0,0 +1,182 @@
+package timecode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Rate defines a frame rate, which can be fractional (for example 29.97)
+// and whether SMPTE timecodes use drop frame numbering
+type Rate struct {
+	frameRate float64
+	nominal   int64
+	dropFrame bool
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+var (
+	ErrInvalidFrameRate = errors.New("Invalid frame rate")
+	ErrInvalidDropFrame = errors.New("Drop frame requires a frame rate of 29.97 or 59.94")
+	ErrInvalidTimecode  = errors.New("Invalid timecode")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// NewRate returns a frame rate, or an error if the frame rate is not valid
+func NewRate(frameRate float64, dropFrame bool) (*Rate, error) {
+	if frameRate <= 0 || math.IsNaN(frameRate) || math.IsInf(frameRate, 0) {
+		return nil, ErrInvalidFrameRate
+	}
+	// The nominal rate is used for counting, so 29.97 counts as 30
+	nominal := int64(math.Ceil(frameRate - 0.01))
+	if dropFrame && nominal%30 != 0 {
+		return nil, ErrInvalidDropFrame
+	}
+	return &Rate{frameRate, nominal, dropFrame}, nil
+}
+
+// FrameRate returns the actual frame rate
+func (this *Rate) FrameRate() float64 {
+	return this.frameRate
+}
+
+// DropFrame returns true if timecodes use drop frame numbering
+func (this *Rate) DropFrame() bool {
+	return this.dropFrame
+}
+
+// Frame returns the nearest frame number for an offset
+func (this *Rate) Frame(offset time.Duration) int64 {
+	return int64(math.Floor(offset.Seconds()*this.frameRate + 0.5))
+}
+
+// Offset returns the offset for a frame number
+func (this *Rate) Offset(frame int64) time.Duration {
+	return time.Duration(math.Floor(float64(frame)*float64(time.Second)/this.frameRate + 0.5))
+}
+
+// Snap returns an offset aligned to the nearest frame
+func (this *Rate) Snap(offset time.Duration) time.Duration {
+	return this.Offset(this.Frame(offset))
+}
+
+// ShotFrames returns the first frame and the frame after the last frame of a
+// shot
+func (this *Rate) ShotFrames(shot *service.ShotAnnotation) (int64, int64) {
+	return this.Frame(shot.StartOffset), this.Frame(shot.EndOffset)
+}
+
+// SegmentFrames returns the first frame and the frame after the last frame of
+// a segment
+func (this *Rate) SegmentFrames(segment *service.Segment) (int64, int64) {
+	return this.Frame(segment.StartOffset), this.Frame(segment.EndOffset)
+}
+
+// SnapAnnotations returns a copy of the annotations with all offsets aligned
+// to the nearest frame, using the Snap hook of each registered feature
+func (this *Rate) SnapAnnotations(annotations *service.Annotations) *service.Annotations {
+	return annotations.Snap(this.Snap)
+}
+
+// Format returns a SMPTE timecode for a frame number, as HH:MM:SS:FF or
+// HH:MM:SS;FF for drop frame
+func (this *Rate) Format(frame int64) string {
+	sign := ""
+	if frame < 0 {
+		sign, frame = "-", -frame
+	}
+	sep := ":"
+	if this.dropFrame {
+		// Skip two frame numbers per minute (per 30 frames), except every
+		// tenth minute
+		drop := this.nominal / 15
+		perMinute := this.nominal*60 - drop
+		perTenMinutes := this.nominal*600 - drop*9
+		tens, rem := frame/perTenMinutes, frame%perTenMinutes
+		frame += drop * 9 * tens
+		if rem >= drop {
+			frame += drop * ((rem - drop) / perMinute)
+		}
+		sep = ";"
+	}
+	ff := frame % this.nominal
+	ss := (frame / this.nominal) % 60
+	mm := (frame / (this.nominal * 60)) % 60
+	hh := frame / (this.nominal * 3600)
+	return fmt.Sprintf("%v%02d:%02d:%02d%v%02d", sign, hh, mm, ss, sep, ff)
+}
+
+// FormatOffset returns a SMPTE timecode for the nearest frame to an offset
+func (this *Rate) FormatOffset(offset time.Duration) string {
+	return this.Format(this.Frame(offset))
+}
+
+// Parse returns the frame number for a SMPTE timecode. Drop frame timecodes
+// may use a semicolon, comma or period before the frames, and a leading minus
+// sign returns a negative frame number as returned by Format
+func (this *Rate) Parse(timecode string) (int64, error) {
+	sign := int64(1)
+	if strings.HasPrefix(timecode, "-") {
+		sign, timecode = -1, timecode[1:]
+	}
+	fields := strings.FieldsFunc(timecode, func(r rune) bool {
+		return r == ':' || r == ';' || r == ',' || r == '.'
+	})
+	if len(fields) != 4 {
+		return 0, ErrInvalidTimecode
+	}
+	values := make([]int64, 4)
+	for i, field := range fields {
+		if value, err := strconv.ParseUint(field, 10, 32); err != nil {
+			return 0, ErrInvalidTimecode
+		} else {
+			values[i] = int64(value)
+		}
+	}
+	hh, mm, ss, ff := values[0], values[1], values[2], values[3]
+	if mm >= 60 || ss >= 60 || ff >= this.nominal {
+		return 0, ErrInvalidTimecode
+	}
+	frame := ((hh*60+mm)*60+ss)*this.nominal + ff
+	if this.dropFrame {
+		drop := this.nominal / 15
+		if ss == 0 && ff < drop && mm%10 != 0 {
+			// These frame numbers are skipped
+			return 0, ErrInvalidTimecode
+		}
+		minutes := hh*60 + mm
+		frame -= drop * (minutes - minutes/10)
+	}
+	return sign * frame, nil
+}
+
+// ParseOffset returns the offset for a SMPTE timecode
+func (this *Rate) ParseOffset(timecode string) (time.Duration, error) {
+	if frame, err := this.Parse(timecode); err != nil {
+		return 0, err
+	} else {
+		return this.Offset(frame), nil
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (this *Rate) String() string {
+	if this.dropFrame {
+		return fmt.Sprintf("%v DF", this.frameRate)
+	}
+	return fmt.Sprintf("%v", this.frameRate)
+}
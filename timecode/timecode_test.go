@@ -0,0 +1,174 @@
+package timecode
+
+import (
+	"testing"
+	"time"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// TESTS
+
+func TestNewRate(t *testing.T) {
+	tests := []struct {
+		frameRate float64
+		dropFrame bool
+		err       error
+	}{
+		{29.97, true, nil},
+		{59.94, true, nil},
+		{25, false, nil},
+		{23.976, false, nil},
+		{30, false, nil},
+		{25, true, ErrInvalidDropFrame},
+		{23.976, true, ErrInvalidDropFrame},
+		{0, false, ErrInvalidFrameRate},
+		{-25, false, ErrInvalidFrameRate},
+	}
+	for _, test := range tests {
+		if _, err := NewRate(test.frameRate, test.dropFrame); err != test.err {
+			t.Errorf("%v: Expected %v, got %v", test.frameRate, test.err, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		frameRate float64
+		dropFrame bool
+		frame     int64
+		timecode  string
+	}{
+		{29.97, true, 0, "00:00:00;00"},
+		{29.97, true, 1799, "00:00:59;29"},
+		{29.97, true, 1800, "00:01:00;02"},
+		{29.97, true, 3598, "00:02:00;02"},
+		{29.97, true, 17981, "00:09:59;29"},
+		{29.97, true, 17982, "00:10:00;00"},
+		{29.97, true, 17983, "00:10:00;01"},
+		{29.97, true, 19782, "00:11:00;02"},
+		{29.97, true, 107892, "01:00:00;00"},
+		{29.97, true, -1, "-00:00:00;01"},
+		{29.97, true, -1800, "-00:01:00;02"},
+		{59.94, true, 3599, "00:00:59;59"},
+		{59.94, true, 3600, "00:01:00;04"},
+		{59.94, true, 35964, "00:10:00;00"},
+		{59.94, true, 39564, "00:11:00;04"},
+		{59.94, true, 215784, "01:00:00;00"},
+		{59.94, true, -3600, "-00:01:00;04"},
+		{25, false, 24, "00:00:00:24"},
+		{25, false, 25, "00:00:01:00"},
+		{25, false, 90000, "01:00:00:00"},
+		{25, false, -26, "-00:00:01:01"},
+		{23.976, false, 23, "00:00:00:23"},
+		{23.976, false, 1440, "00:01:00:00"},
+		{23.976, false, 86400, "01:00:00:00"},
+		{23.976, false, -24, "-00:00:01:00"},
+	}
+	for _, test := range tests {
+		rate, err := NewRate(test.frameRate, test.dropFrame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if timecode := rate.Format(test.frame); timecode != test.timecode {
+			t.Errorf("%v %v: Expected %v, got %v", rate, test.frame, test.timecode, timecode)
+		}
+		if frame, err := rate.Parse(test.timecode); err != nil {
+			t.Errorf("%v %v: %v", rate, test.timecode, err)
+		} else if frame != test.frame {
+			t.Errorf("%v %v: Expected frame %v, got %v", rate, test.timecode, test.frame, frame)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		frameRate float64
+		dropFrame bool
+	}{
+		{29.97, true},
+		{59.94, true},
+		{25, false},
+		{23.976, false},
+	}
+	for _, test := range tests {
+		rate, err := NewRate(test.frameRate, test.dropFrame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for frame := int64(-40000); frame < 300000; frame += 7 {
+			timecode := rate.Format(frame)
+			if parsed, err := rate.Parse(timecode); err != nil {
+				t.Fatalf("%v %v: %v", rate, timecode, err)
+			} else if parsed != frame {
+				t.Fatalf("%v %v: Expected frame %v, got %v", rate, timecode, frame, parsed)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		frameRate float64
+		dropFrame bool
+		timecode  string
+		frame     int64
+		err       error
+	}{
+		{29.97, true, "00:01:00;00", 0, ErrInvalidTimecode},
+		{29.97, true, "00:01:00;01", 0, ErrInvalidTimecode},
+		{29.97, true, "00:59:00;01", 0, ErrInvalidTimecode},
+		{29.97, true, "-00:01:00;00", 0, ErrInvalidTimecode},
+		{29.97, true, "00:10:00;00", 17982, nil},
+		{29.97, true, "00:10:00;01", 17983, nil},
+		{29.97, true, "01:00:00;00", 107892, nil},
+		{29.97, true, "00:01:00,02", 1800, nil},
+		{29.97, true, "00:01:00.02", 1800, nil},
+		{59.94, true, "00:01:00;03", 0, ErrInvalidTimecode},
+		{59.94, true, "00:01:00;04", 3600, nil},
+		{59.94, true, "00:10:00;00", 35964, nil},
+		{29.97, false, "00:01:00:00", 1800, nil},
+		{25, false, "00:00:00:25", 0, ErrInvalidTimecode},
+		{25, false, "00:60:00:00", 0, ErrInvalidTimecode},
+		{25, false, "00:00:60:00", 0, ErrInvalidTimecode},
+		{25, false, "00:00:00", 0, ErrInvalidTimecode},
+		{25, false, "00:00:00:0a", 0, ErrInvalidTimecode},
+		{25, false, "--00:00:01:00", 0, ErrInvalidTimecode},
+	}
+	for _, test := range tests {
+		rate, err := NewRate(test.frameRate, test.dropFrame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame, err := rate.Parse(test.timecode); err != test.err {
+			t.Errorf("%v %v: Expected %v, got %v", rate, test.timecode, test.err, err)
+		} else if frame != test.frame {
+			t.Errorf("%v %v: Expected frame %v, got %v", rate, test.timecode, test.frame, frame)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	rate, err := NewRate(29.97, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame := rate.Frame(time.Minute); frame != 1798 {
+		t.Errorf("Unexpected frame %v", frame)
+	}
+	// The frame rate is exactly 29.97, rather than 30000/1001
+	minute := 60060060060 * time.Nanosecond
+	if offset := rate.Offset(1800); offset != minute {
+		t.Errorf("Unexpected offset %v", offset)
+	}
+	if timecode := rate.FormatOffset(60060 * time.Millisecond); timecode != "00:01:00;02" {
+		t.Errorf("Unexpected timecode %v", timecode)
+	}
+	if offset, err := rate.ParseOffset("00:01:00;02"); err != nil {
+		t.Error(err)
+	} else if offset != minute {
+		t.Errorf("Unexpected offset %v", offset)
+	}
+	if snap := rate.Snap(60070 * time.Millisecond); snap != minute {
+		t.Errorf("Unexpected snap %v", snap)
+	}
+}
@@ -0,0 +1,269 @@
+package timeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/djthorpe/VideoIntelligence/service"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC STRUCTS
+
+// Timeline indexes shots, labels and explicit content annotations by time,
+// so that they can be queried by offset or range
+type Timeline struct {
+	events []*Event
+	maxEnd []time.Duration
+	shots  []*Event
+}
+
+// Event is a single shot, label segment, label frame or explicit content
+// frame. Frames have the same start and end offset
+type Event struct {
+	Kind       Kind
+	Start      time.Duration
+	End        time.Duration
+	Shot       int
+	Label      *service.EntityAnnotation
+	Confidence float64
+	Likelihood service.LikelihoodType
+}
+
+// Change is a point on the timeline where events start or end. An event
+// with the same start and end offset appears in both lists
+type Change struct {
+	Offset  time.Duration
+	Started []*Event
+	Ended   []*Event
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// CONSTANTS
+
+// Kind defines the type of annotation for an event, and can be combined
+// when querying
+type Kind uint
+
+const (
+	KIND_NONE          Kind = 0
+	KIND_SHOT          Kind = 1 << iota
+	KIND_SHOT_LABEL    Kind = 1 << iota
+	KIND_SEGMENT_LABEL Kind = 1 << iota
+	KIND_FRAME_LABEL   Kind = 1 << iota
+	KIND_EXPLICIT      Kind = 1 << iota
+	KIND_LABEL         Kind = KIND_SHOT_LABEL | KIND_SEGMENT_LABEL | KIND_FRAME_LABEL
+	KIND_ALL           Kind = KIND_SHOT | KIND_LABEL | KIND_EXPLICIT
+)
+
+var (
+	ErrInvalidShot = errors.New("Invalid shot")
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// PUBLIC METHODS
+
+// New returns a timeline for annotations
+func New(annotations *service.Annotations) *Timeline {
+	this := new(Timeline)
+	for i, shot := range annotations.Shots {
+		event := &Event{Kind: KIND_SHOT, Start: shot.StartOffset, End: shot.EndOffset, Shot: i}
+		this.events = append(this.events, event)
+		this.shots = append(this.shots, event)
+	}
+	for _, group := range []struct {
+		kind   Kind
+		labels []*service.EntityAnnotation
+	}{
+		{KIND_SHOT_LABEL, annotations.ShotLabels},
+		{KIND_SEGMENT_LABEL, annotations.SegmentLabels},
+		{KIND_FRAME_LABEL, annotations.FrameLabels},
+	} {
+		for _, label := range group.labels {
+			for _, segment := range label.Segments {
+				this.events = append(this.events, &Event{
+					Kind:       group.kind,
+					Start:      segment.StartOffset,
+					End:        segment.EndOffset,
+					Shot:       -1,
+					Label:      label,
+					Confidence: segment.Confidence,
+				})
+			}
+		}
+	}
+	for _, annotation := range annotations.ExplicitContent {
+		this.events = append(this.events, &Event{
+			Kind:       KIND_EXPLICIT,
+			Start:      annotation.Offset,
+			End:        annotation.Offset,
+			Shot:       -1,
+			Likelihood: annotation.Likelihood,
+		})
+	}
+
+	// Order the events by start offset, and build the index
+	sort.SliceStable(this.events, func(i, j int) bool {
+		return this.events[i].Start < this.events[j].Start
+	})
+	this.maxEnd = make([]time.Duration, len(this.events))
+	this.index(0, len(this.events))
+	return this
+}
+
+// Shots returns the shot events in order
+func (this *Timeline) Shots() []*Event {
+	return this.shots
+}
+
+// Events returns all events of the given kinds, in order of start offset
+func (this *Timeline) Events(kinds Kind) []*Event {
+	events := make([]*Event, 0)
+	for _, event := range this.events {
+		if event.Kind&kinds != 0 {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// At returns the events of the given kinds which are active at an offset, in
+// order of start offset. Segments include their start offset but not their
+// end offset, and frames are only returned at their exact offset
+func (this *Timeline) At(offset time.Duration, kinds Kind) []*Event {
+	return this.Range(offset, offset, kinds)
+}
+
+// Range returns the events of the given kinds which overlap the range from
+// start up to (but not including) end, in order of start offset
+func (this *Timeline) Range(start, end time.Duration, kinds Kind) []*Event {
+	events := make([]*Event, 0)
+	if end < start {
+		return events
+	}
+	return this.query(0, len(this.events), start, end, kinds, events)
+}
+
+// LabelsInShot returns the label events which are active during a shot,
+// where shots are numbered from zero
+func (this *Timeline) LabelsInShot(shot int) ([]*Event, error) {
+	if shot < 0 || shot >= len(this.shots) {
+		return nil, ErrInvalidShot
+	}
+	return this.Range(this.shots[shot].Start, this.shots[shot].End, KIND_LABEL), nil
+}
+
+// Changes returns the offsets at which events of the given kinds start or
+// end, in order
+func (this *Timeline) Changes(kinds Kind) []*Change {
+	changes := make(map[time.Duration]*Change)
+	change := func(offset time.Duration) *Change {
+		if _, exists := changes[offset]; exists == false {
+			changes[offset] = &Change{Offset: offset}
+		}
+		return changes[offset]
+	}
+	for _, event := range this.events {
+		if event.Kind&kinds == 0 {
+			continue
+		}
+		change(event.Start).Started = append(change(event.Start).Started, event)
+		change(event.End).Ended = append(change(event.End).Ended, event)
+	}
+	sorted := make([]*Change, 0, len(changes))
+	for _, change := range changes {
+		sorted = append(sorted, change)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+	return sorted
+}
+
+// Overlaps returns true if the event is active during the range from start
+// up to (but not including) end, or at start when start and end are the same
+func (this *Event) Overlaps(start, end time.Duration) bool {
+	if this.Start == this.End {
+		return this.Start == start || (this.Start > start && this.Start < end)
+	}
+	return this.Start <= start && this.End > start || this.Start > start && this.Start < end
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// PRIVATE METHODS
+
+// index sets the maximum end offset for each subtree of the implicit binary
+// search tree over the sorted events from lo up to hi, where the root of
+// each subtree is the middle element
+func (this *Timeline) index(lo, hi int) time.Duration {
+	if lo >= hi {
+		return -1
+	}
+	mid := (lo + hi) / 2
+	maxEnd := this.events[mid].End
+	if left := this.index(lo, mid); left > maxEnd {
+		maxEnd = left
+	}
+	if right := this.index(mid+1, hi); right > maxEnd {
+		maxEnd = right
+	}
+	this.maxEnd[mid] = maxEnd
+	return maxEnd
+}
+
+// query appends the overlapping events in the subtree from lo up to hi,
+// skipping subtrees which end before the start of the range or start after
+// the end of the range
+func (this *Timeline) query(lo, hi int, start, end time.Duration, kinds Kind, events []*Event) []*Event {
+	if lo >= hi {
+		return events
+	}
+	mid := (lo + hi) / 2
+	if this.maxEnd[mid] < start {
+		return events
+	}
+	events = this.query(lo, mid, start, end, kinds, events)
+	event := this.events[mid]
+	if event.Start > end || (event.Start == end && end > start) {
+		return events
+	}
+	if event.Kind&kinds != 0 && event.Overlaps(start, end) {
+		events = append(events, event)
+	}
+	return this.query(mid+1, hi, start, end, kinds, events)
+}
+
+///////////////////////////////////////////////////////////////////////////////
+// STRINGIFY
+
+func (k Kind) String() string {
+	switch k {
+	case KIND_NONE:
+		return "KIND_NONE"
+	case KIND_SHOT:
+		return "KIND_SHOT"
+	case KIND_SHOT_LABEL:
+		return "KIND_SHOT_LABEL"
+	case KIND_SEGMENT_LABEL:
+		return "KIND_SEGMENT_LABEL"
+	case KIND_FRAME_LABEL:
+		return "KIND_FRAME_LABEL"
+	case KIND_EXPLICIT:
+		return "KIND_EXPLICIT"
+	default:
+		return "[?? Invalid Kind value]"
+	}
+}
+
+func (e *Event) String() string {
+	switch e.Kind {
+	case KIND_SHOT:
+		return fmt.Sprintf("<%v shot=%v start=%v end=%v>", e.Kind, e.Shot, e.Start, e.End)
+	case KIND_EXPLICIT:
+		return fmt.Sprintf("<%v offset=%v likelihood=%v>", e.Kind, e.Start, e.Likelihood)
+	default:
+		return fmt.Sprintf("<%v label=%v start=%v end=%v confidence=%v>", e.Kind, e.Label.Entity.Description, e.Start, e.End, e.Confidence)
+	}
+}
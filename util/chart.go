@@ -0,0 +1,226 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Chart defines rows of spans, ticks and levels against a time axis, which
+// are scaled to the width of a terminal
+type Chart struct {
+	title    string
+	duration time.Duration
+	format   func(time.Duration) string
+	rows     []*chartRow
+}
+
+type chartRow struct {
+	name   string
+	kind   chartKind
+	spans  []chartSpan
+	levels []int
+}
+
+type chartSpan struct {
+	start, end time.Duration
+	level      int
+}
+
+type chartKind uint
+
+const (
+	chart_SPANS chartKind = iota
+	chart_TICKS
+	chart_HEAT
+)
+
+const (
+	// Default width when not a terminal
+	chart_DEFAULT_WIDTH = 80
+	// Maximum width of the row names
+	chart_MAX_NAME_WIDTH = 24
+	// ANSI escape codes
+	chart_RESET = "\x1b[0m"
+	chart_SPAN  = "\x1b[36m"
+	chart_TICK  = "\x1b[33m"
+)
+
+var (
+	// Heat levels from none to highest, in ASCII and colour
+	chart_heat_ascii  = []string{" ", ".", ":", "-", "=", "#"}
+	chart_heat_colour = []string{" ", "\x1b[32m█", "\x1b[92m█", "\x1b[93m█", "\x1b[33m█", "\x1b[31m█"}
+)
+
+// NewChart returns an empty chart for a time axis of a certain duration
+func NewChart(title string, duration time.Duration) *Chart {
+	this := new(Chart)
+	this.title = title
+	this.duration = duration
+	this.format = func(offset time.Duration) string { return offset.String() }
+	this.rows = make([]*chartRow, 0)
+	return this
+}
+
+// SetFormat sets the function used to format offsets on the axis
+func (this *Chart) SetFormat(format func(time.Duration) string) {
+	this.format = format
+}
+
+// AddSpans appends a row where each span is drawn from start to end offset
+func (this *Chart) AddSpans(name string, starts, ends []time.Duration) {
+	row := this.newRow(name, chart_SPANS)
+	for i := range starts {
+		row.spans = append(row.spans, chartSpan{starts[i], ends[i], 1})
+	}
+}
+
+// AddTicks appends a row with a mark at each offset
+func (this *Chart) AddTicks(name string, offsets []time.Duration) {
+	row := this.newRow(name, chart_TICKS)
+	for _, offset := range offsets {
+		row.spans = append(row.spans, chartSpan{offset, offset, 1})
+	}
+}
+
+// AddHeat appends a row where each offset has a level between zero and five,
+// which extends until the next offset. The highest level is drawn when
+// several offsets fall within the same column
+func (this *Chart) AddHeat(name string, offsets []time.Duration, levels []int) {
+	row := this.newRow(name, chart_HEAT)
+	for i, offset := range offsets {
+		end := this.duration
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		row.spans = append(row.spans, chartSpan{offset, end, levels[i]})
+	}
+}
+
+// RenderTerminal outputs the chart to stdout, using the width of the
+// terminal and colour when stdout is a terminal
+func (this *Chart) RenderTerminal() {
+	width, colour := chart_DEFAULT_WIDTH, false
+	if fd := int(os.Stdout.Fd()); term.IsTerminal(fd) {
+		colour = true
+		if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+			width = w
+		}
+	}
+	this.Render(os.Stdout, width, colour)
+}
+
+// Render outputs the chart with a total width in characters
+func (this *Chart) Render(w io.Writer, width int, colour bool) {
+	nameWidth := 0
+	for _, row := range this.rows {
+		if width := utf8.RuneCountInString(row.name); width > nameWidth {
+			nameWidth = width
+		}
+	}
+	if nameWidth > chart_MAX_NAME_WIDTH {
+		nameWidth = chart_MAX_NAME_WIDTH
+	}
+	columns := width - nameWidth - 3
+	if columns < 10 {
+		columns = 10
+	}
+
+	fmt.Fprintln(w, this.title)
+	for _, row := range this.rows {
+		name := truncateName(row.name, nameWidth)
+		fmt.Fprintf(w, "%-*s |%s|\n", nameWidth, name, this.renderRow(row, columns, colour))
+	}
+
+	// Axis with the start and end offsets
+	start, end := this.format(0), this.format(this.duration)
+	padding := columns + 2 - len(start) - len(end)
+	if padding < 1 {
+		padding = 1
+	}
+	fmt.Fprintf(w, "%-*s %s%s%s\n", nameWidth, "", start, strings.Repeat(" ", padding), end)
+}
+
+////////////////////////
+
+func (this *Chart) newRow(name string, kind chartKind) *chartRow {
+	row := &chartRow{name: name, kind: kind, spans: make([]chartSpan, 0)}
+	this.rows = append(this.rows, row)
+	return row
+}
+
+// column returns the column for an offset
+func (this *Chart) column(offset time.Duration, columns int) int {
+	if this.duration <= 0 {
+		return 0
+	}
+	column := int(int64(offset) * int64(columns) / int64(this.duration))
+	if column >= columns {
+		column = columns - 1
+	}
+	if column < 0 {
+		column = 0
+	}
+	return column
+}
+
+func (this *Chart) renderRow(row *chartRow, columns int, colour bool) string {
+	// Determine the level for each column
+	levels := make([]int, columns)
+	for _, span := range row.spans {
+		first, last := this.column(span.start, columns), this.column(span.end, columns)
+		if span.end > span.start {
+			// The end offset is exclusive
+			last = this.column(span.end-1, columns)
+		}
+		for i := first; i <= last; i++ {
+			if span.level > levels[i] {
+				levels[i] = span.level
+			}
+		}
+	}
+
+	// Draw the columns
+	var buf strings.Builder
+	for _, level := range levels {
+		switch {
+		case level == 0:
+			buf.WriteString(" ")
+		case row.kind == chart_HEAT && colour:
+			buf.WriteString(chart_heat_colour[clampLevel(level)] + chart_RESET)
+		case row.kind == chart_HEAT:
+			buf.WriteString(chart_heat_ascii[clampLevel(level)])
+		case row.kind == chart_TICKS && colour:
+			buf.WriteString(chart_TICK + "|" + chart_RESET)
+		case row.kind == chart_TICKS:
+			buf.WriteString("|")
+		case colour:
+			buf.WriteString(chart_SPAN + "█" + chart_RESET)
+		default:
+			buf.WriteString("#")
+		}
+	}
+	return buf.String()
+}
+
+// truncateName returns a name which is no wider than a number of
+// characters, ending with a tilde when it has been shortened
+func truncateName(name string, width int) string {
+	if utf8.RuneCountInString(name) <= width {
+		return name
+	}
+	runes := []rune(name)
+	return string(runes[:width-1]) + "~"
+}
+
+func clampLevel(level int) int {
+	if level >= len(chart_heat_ascii) {
+		return len(chart_heat_ascii) - 1
+	}
+	return level
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/olekukonko/tablewriter"
@@ -41,8 +42,14 @@ func (this *Output) AppendMap(row map[string]interface{}) {
 	}
 }
 
+// RenderASCII writes the table to stdout
 func (this *Output) RenderASCII() {
-	table := tablewriter.NewWriter(os.Stdout)
+	this.RenderASCIIWriter(os.Stdout)
+}
+
+// RenderASCIIWriter writes the table to a writer
+func (this *Output) RenderASCIIWriter(w io.Writer) {
+	table := tablewriter.NewWriter(w)
 	table.SetHeader(this.columns)
 	for _, r := range this.rows {
 		table.Append(r.row(this.columns))
@@ -0,0 +1,173 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Progress displays the progress of several operations. On a terminal the
+// display is redrawn in place with a bar for each row, otherwise a line is
+// logged for each operation at an interval or when its state changes
+type Progress struct {
+	w        io.Writer
+	live     bool
+	width    int
+	interval time.Duration
+	logged   time.Time
+	states   map[string]string
+	lines    int
+}
+
+// ProgressRow is the progress of an operation, or of a part of an operation
+// when the level is greater than zero. The time remaining is shown when
+// estimated is true
+type ProgressRow struct {
+	Name      string
+	Level     int
+	Percent   float64
+	Elapsed   time.Duration
+	Remaining time.Duration
+	Estimated bool
+	State     string
+}
+
+const (
+	// Width of the bar in characters
+	progress_BAR_WIDTH = 20
+	// Maximum width of the row names
+	progress_MAX_NAME_WIDTH = 48
+	// ANSI escape codes to move up a line and to clear a line
+	progress_UP    = "\x1b[%dA"
+	progress_CLEAR = "\x1b[2K"
+)
+
+// NewProgress returns a progress display which is redrawn in place when
+// stdout is a terminal, or otherwise logs lines to stderr at most once in
+// each interval
+func NewProgress(interval time.Duration) *Progress {
+	this := new(Progress)
+	this.w = os.Stderr
+	this.interval = interval
+	this.states = make(map[string]string)
+	if fd := int(os.Stdout.Fd()); term.IsTerminal(fd) {
+		this.w = os.Stdout
+		this.live = true
+		this.width = chart_DEFAULT_WIDTH
+		if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+			this.width = w
+		}
+	}
+	return this
+}
+
+// Update displays the current progress of each row
+func (this *Progress) Update(rows []*ProgressRow) {
+	if this.live {
+		this.redraw(rows)
+	} else {
+		this.log(rows, false)
+	}
+}
+
+// Done displays the final progress of each row
+func (this *Progress) Done(rows []*ProgressRow) {
+	if this.live {
+		this.redraw(rows)
+	} else {
+		this.log(rows, true)
+	}
+}
+
+////////////////////////
+
+// redraw replaces the lines drawn previously with a line for each row
+func (this *Progress) redraw(rows []*ProgressRow) {
+	var buf strings.Builder
+	if this.lines > 0 {
+		fmt.Fprintf(&buf, progress_UP, this.lines)
+	}
+	nameWidth := 0
+	for _, row := range rows {
+		if width := utf8.RuneCountInString(row.Name) + 2*row.Level; width > nameWidth {
+			nameWidth = width
+		}
+	}
+	if nameWidth > progress_MAX_NAME_WIDTH {
+		nameWidth = progress_MAX_NAME_WIDTH
+	}
+	for _, row := range rows {
+		// Lines are truncated by character so that names aren't split
+		line := this.line(row, nameWidth)
+		if runes := []rune(line); len(runes) > this.width-1 {
+			line = string(runes[:this.width-1])
+		}
+		buf.WriteString(progress_CLEAR + line + "\n")
+	}
+	// Clear any lines left over from a previous draw
+	for i := len(rows); i < this.lines; i++ {
+		buf.WriteString(progress_CLEAR + "\n")
+	}
+	if len(rows) > this.lines {
+		this.lines = len(rows)
+	}
+	io.WriteString(this.w, buf.String())
+}
+
+// log outputs a line for each operation when the interval has passed, the
+// state of the operation has changed or all the rows are final
+func (this *Progress) log(rows []*ProgressRow, final bool) {
+	now := time.Now()
+	due := final || now.Sub(this.logged) >= this.interval
+	for _, row := range rows {
+		if row.Level > 0 {
+			continue
+		}
+		changed := this.states[row.Name] != row.State
+		if due || changed {
+			fmt.Fprintln(this.w, this.summary(row))
+			this.states[row.Name] = row.State
+		}
+	}
+	if due {
+		this.logged = now
+	}
+}
+
+// line returns a row with a bar, such as "video.mp4 [####------]  45%  1m02s  ETA 2m10s  running"
+func (this *Progress) line(row *ProgressRow, nameWidth int) string {
+	name := truncateName(strings.Repeat("  ", row.Level)+row.Name, nameWidth)
+	filled := int(row.Percent * progress_BAR_WIDTH / 100)
+	if filled > progress_BAR_WIDTH {
+		filled = progress_BAR_WIDTH
+	} else if filled < 0 {
+		filled = 0
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", progress_BAR_WIDTH-filled)
+	return fmt.Sprintf("%-*s [%s] %3.0f%%  %-7s  %-12s  %s", nameWidth, name, bar, row.Percent, formatElapsed(row.Elapsed), formatRemaining(row), row.State)
+}
+
+// summary returns a row as a log line, such as "video.mp4: 45% ETA 2m10s elapsed 1m02s running"
+func (this *Progress) summary(row *ProgressRow) string {
+	line := fmt.Sprintf("%v: %.0f%%", row.Name, row.Percent)
+	if remaining := formatRemaining(row); remaining != "" {
+		line += " " + remaining
+	}
+	return line + " elapsed " + formatElapsed(row.Elapsed) + " " + row.State
+}
+
+func formatElapsed(elapsed time.Duration) string {
+	return elapsed.Round(time.Second).String()
+}
+
+func formatRemaining(row *ProgressRow) string {
+	if row.Estimated == false || row.Percent >= 100 {
+		return ""
+	}
+	return "ETA " + row.Remaining.Round(time.Second).String()
+}
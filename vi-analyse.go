@@ -5,18 +5,81 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/djthorpe/VideoIntelligence/export"
+	"github.com/djthorpe/VideoIntelligence/moderation"
+	"github.com/djthorpe/VideoIntelligence/policy"
+	"github.com/djthorpe/VideoIntelligence/probe"
+	"github.com/djthorpe/VideoIntelligence/rekognition"
 	"github.com/djthorpe/VideoIntelligence/service"
+	"github.com/djthorpe/VideoIntelligence/timecode"
 	"github.com/djthorpe/VideoIntelligence/util"
 )
 
+const (
+	// Interval between progress lines when output is not a terminal
+	progress_LOG_INTERVAL = 10 * time.Second
+)
+
+var (
+	// Exit codes for each policy verdict
+	verdictExitCode = map[policy.Verdict]int{
+		policy.VERDICT_ALLOW:  0,
+		policy.VERDICT_REVIEW: 10,
+		policy.VERDICT_BLOCK:  20,
+	}
+)
+
 var (
 	FlagServiceAccount  = flag.String("sa", ".yt-video-intelligence.json", "Service Account JSON")
 	FlagDebug           = flag.Bool("debug", false, "Debug")
 	FlagShotChange      = flag.Bool("shot", false, "Annotate for Shot Changes")
 	FlagLabel           = flag.Bool("label", true, "Annotate for Labels")
 	FlagExplicitContent = flag.Bool("explicit", false, "Annotate for Explicit Content")
+	FlagObjects         = flag.Bool("objects", false, "Annotate for Object Tracking")
+	FlagText            = flag.Bool("text", false, "Annotate for on-screen Text")
+	FlagTextLanguage    = flag.String("text-language", "", "Comma-separated language hints for text detection, for example en-US,fr-FR")
+	FlagTextSearch      = flag.String("text-search", "", "Only output detected text which contains this value")
+	FlagSpeech          = flag.Bool("speech", false, "Annotate for Speech Transcription")
+	FlagSpeechLanguage  = flag.String("speech-language", "en-US", "Language code for speech transcription")
+	FlagSpeechAlts      = flag.Int64("speech-alternatives", 1, "Maximum number of transcripts for each section of speech")
+	FlagSpeechProfanity = flag.Bool("speech-profanity", false, "Filter profanities from transcripts")
+	FlagSpeechPunctuate = flag.Bool("speech-punctuation", true, "Add punctuation to transcripts")
+	FlagSpeechSpeakers  = flag.Int64("speech-speakers", 0, "Expected number of speakers, to label speakers in transcripts")
+	FlagSpeechPhrases   = flag.String("speech-phrases", "", "Comma-separated words and phrases which are likely to be spoken")
+	FlagPeople          = flag.Bool("people", false, "Annotate for Person Detection")
+	FlagPeopleDetail    = flag.Bool("people-detail", false, "Include attributes and pose landmarks of detected people")
+	FlagFaces           = flag.Bool("faces", false, "Annotate for Face Detection")
+	FlagFacesDetail     = flag.Bool("faces-detail", false, "Include attributes of detected faces")
+	FlagLogos           = flag.Bool("logos", false, "Annotate for Logo Recognition")
+	FlagFeatures        = flag.String("features", "", "Comma-separated feature names to annotate, for example LOGO_RECOGNITION")
+	FlagFormat          = flag.String("format", "table", "Output format (table, edl, otio, html, json, timeline, transcript, dialogue, srt, vtt)")
+	FlagTop             = flag.Uint("top", 20, "Number of labels for timeline output")
+	FlagFrameRate       = flag.Float64("fps", 25, "Frame rate for timecodes")
+	FlagDropFrame       = flag.Bool("dropframe", false, "Use drop frame timecodes")
+	FlagTimecode        = flag.Bool("timecode", false, "Output offsets as SMPTE timecodes")
+	FlagNoCache         = flag.Bool("no-cache", false, "Do not use cached annotations")
+	FlagRefresh         = flag.Bool("refresh", false, "Replace cached annotations")
+	FlagCacheTTL        = flag.Duration("cache-ttl", 7*24*time.Hour, "Expiry time for cached annotations")
+	FlagSegments        = flag.String("segments", "", "Comma-separated segments to annotate, for example 0s-30s,1m-1m30s")
+	FlagEstimate        = flag.Bool("estimate", false, "Estimate the cost without submitting")
+	FlagManifest        = flag.String("manifest", "", "File of uri,duration lines for estimates")
+	FlagPricing         = flag.String("pricing", "", "JSON price table for estimates")
+	FlagBudgetDaily     = flag.Float64("budget-daily", 0, "Maximum estimated spend per day, including recorded usage")
+	FlagBudgetMonthly   = flag.Float64("budget-monthly", 0, "Maximum estimated spend per month, including recorded usage")
+	FlagBudgetRun       = flag.Float64("budget-run", 0, "Maximum estimated spend for this run")
+	FlagExplicitRanges  = flag.Bool("explicit-ranges", false, "Output flagged explicit content ranges")
+	FlagExplicitEnter   = flag.String("explicit-enter", "LIKELY", "Likelihood which starts a flagged range")
+	FlagExplicitExit    = flag.String("explicit-exit", "POSSIBLE", "Likelihood below which a flagged range ends")
+	FlagExplicitMin     = flag.Duration("explicit-min", 0, "Minimum duration of a flagged range")
+	FlagExplicitGap     = flag.Duration("explicit-gap", time.Second, "Merge flagged ranges separated by no more than this gap")
+	FlagPolicy          = flag.String("policy", "", "YAML or JSON moderation policy, which sets the exit code")
+	FlagVersion         = flag.String("api", "v1beta2", "API version (v1, v1beta2, v1beta1)")
+	FlagProvider        = flag.String("provider", "google", "Annotation provider (google, rekognition)")
 )
 
 func filenameToAbsolute(filename string) (string, error) {
@@ -28,7 +91,7 @@ func filenameToAbsolute(filename string) (string, error) {
 	}
 }
 
-func annotationFlags() service.AnnotationType {
+func annotationFlags() (service.AnnotationType, error) {
 	var flags service.AnnotationType
 	if *FlagShotChange {
 		flags |= service.ANNOTATION_SHOT_CHANGE
@@ -36,132 +99,880 @@ func annotationFlags() service.AnnotationType {
 	if *FlagLabel {
 		flags |= service.ANNOTATION_LABEL
 	}
-	if *FlagExplicitContent {
+	if *FlagExplicitContent || *FlagExplicitRanges {
 		flags |= service.ANNOTATION_EXPLICIT_CONTENT
 	}
-	return flags
+	if *FlagObjects {
+		flags |= service.ANNOTATION_OBJECT_TRACKING
+	}
+	if *FlagText || *FlagTextSearch != "" {
+		flags |= service.ANNOTATION_TEXT_DETECTION
+	}
+	if *FlagSpeech {
+		flags |= service.ANNOTATION_SPEECH
+	}
+	if *FlagPeople {
+		flags |= service.ANNOTATION_PERSON_DETECTION
+	}
+	if *FlagFaces {
+		flags |= service.ANNOTATION_FACE_DETECTION
+	}
+	if *FlagLogos {
+		flags |= service.ANNOTATION_LOGO_RECOGNITION
+	}
+	// Registered features can also be requested by name
+	if features, err := service.ParseFeatures(*FlagFeatures); err != nil {
+		return service.ANNOTATION_NONE, err
+	} else {
+		flags |= features
+	}
+	return flags, nil
 }
 
-func outputResponseEntity(output *util.Output, t string, label *service.EntityAnnotation) {
-	for i := range label.Segments {
-		entity_description := label.Entity.Description
-		for i, category := range label.Categories {
-			if i == 0 {
-				entity_description = entity_description + " > "
-			} else {
-				entity_description = entity_description + ", "
+// progressRows returns a row of progress for each operation, followed by a
+// row for each feature of the operation
+func progressRows(statuses []*service.Status, started map[string]time.Time, failed map[string]error) []*util.ProgressRow {
+	rows := make([]*util.ProgressRow, 0, len(statuses))
+	for _, status := range statuses {
+		// Elapsed time stops when the operation has completed
+		finished := time.Now()
+		if status.Done && status.Updated.After(started[status.Name]) {
+			finished = status.Updated
+		} else if status.Done {
+			finished = started[status.Name]
+		}
+		row := &util.ProgressRow{
+			Name:    status.Uri,
+			Percent: status.PercentComplete(),
+			Elapsed: finished.Sub(started[status.Name]),
+			State:   "running",
+		}
+		row.Remaining, row.Estimated = status.Remaining()
+		if _, exists := failed[status.Name]; exists {
+			row.State = "failed"
+		} else if status.Cached {
+			row.State = "cached"
+		} else if status.Done {
+			row.State = "done"
+		}
+		rows = append(rows, row)
+		for _, annotationType := range status.Type {
+			rows = append(rows, progressFeatureRow(status, annotationType, row))
+		}
+	}
+	return rows
+}
+
+// progressFeatureRow returns a row of progress for an annotation type
+func progressFeatureRow(status *service.Status, annotationType service.AnnotationType, operation *util.ProgressRow) *util.ProgressRow {
+	row := &util.ProgressRow{
+		Name:    strings.ToLower(strings.TrimPrefix(annotationType.String(), "ANNOTATION_")),
+		Level:   1,
+		Elapsed: operation.Elapsed,
+		State:   "waiting",
+	}
+	row.Remaining, row.Estimated = status.RemainingFor(annotationType)
+	if progress, exists := status.Progress[annotationType]; exists {
+		row.Percent = float64(progress.Percent)
+		row.State = "running"
+		if progress.Done {
+			row.State = "done"
+			if progress.StartTime.IsZero() == false && progress.UpdateTime.After(progress.StartTime) {
+				row.Elapsed = progress.UpdateTime.Sub(progress.StartTime)
 			}
-			entity_description = entity_description + category.Description
 		}
-		if i == 0 {
+	}
+	if status.Done {
+		row.Percent, row.State = 100, operation.State
+	}
+	return row
+}
+
+// formatOffset returns an offset as a timecode when the -timecode flag is set
+func formatOffset(rate *timecode.Rate, offset time.Duration) interface{} {
+	if *FlagTimecode {
+		return rate.FormatOffset(offset)
+	} else {
+		return offset
+	}
+}
+
+// detectionConfig returns the detection parameters from the command-line
+// flags, or nil if there are none
+func detectionConfig() (*service.Config, error) {
+	if *FlagSegments == "" && *FlagTextLanguage == "" && *FlagSpeech == false && *FlagPeopleDetail == false && *FlagFacesDetail == false {
+		return nil, nil
+	}
+	config := &service.Config{
+		PersonAttributes: *FlagPeopleDetail,
+		PersonLandmarks:  *FlagPeopleDetail,
+		FaceAttributes:   *FlagFacesDetail,
+	}
+	if *FlagSpeech {
+		config.Speech = &service.SpeechConfig{
+			LanguageCode:    *FlagSpeechLanguage,
+			MaxAlternatives: *FlagSpeechAlts,
+			FilterProfanity: *FlagSpeechProfanity,
+			Punctuation:     *FlagSpeechPunctuate,
+			Speakers:        *FlagSpeechSpeakers,
+		}
+		if *FlagSpeechPhrases != "" {
+			for _, phrase := range strings.Split(*FlagSpeechPhrases, ",") {
+				config.Speech.Phrases = append(config.Speech.Phrases, strings.TrimSpace(phrase))
+			}
+		}
+	}
+	if *FlagTextLanguage != "" {
+		for _, language := range strings.Split(*FlagTextLanguage, ",") {
+			config.TextLanguageHints = append(config.TextLanguageHints, strings.TrimSpace(language))
+		}
+	}
+	if *FlagSegments == "" {
+		return config, nil
+	}
+	for _, value := range strings.Split(*FlagSegments, ",") {
+		offsets := strings.SplitN(strings.TrimSpace(value), "-", 2)
+		if len(offsets) != 2 {
+			return nil, fmt.Errorf("Invalid segment: %v", value)
+		}
+		start, err := time.ParseDuration(offsets[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid segment: %v", value)
+		}
+		end, err := time.ParseDuration(offsets[1])
+		if err != nil || end <= start {
+			return nil, fmt.Errorf("Invalid segment: %v", value)
+		}
+		config.Segments = append(config.Segments, &service.Segment{StartOffset: start, EndOffset: end})
+	}
+	return config, nil
+}
+
+func outputResponse(status *service.Status, rate *timecode.Rate, output *util.Output) {
+	annotations := status.Annotations
+	if *FlagTextSearch != "" {
+		filtered := *annotations
+		filtered.Text = annotations.SearchText(*FlagTextSearch)
+		annotations = &filtered
+	}
+	// Each registered feature renders its own rows
+	for _, row := range annotations.Render() {
+		values := map[string]interface{}{
+			"type":        row.Type,
+			"entity":      row.Entity,
+			"description": row.Description,
+			"start":       formatOffset(rate, row.Start),
+			"end":         formatOffset(rate, row.End),
+			"value":       row.Value,
+		}
+		if row.Confidence != nil {
+			values["confidence"] = row.Confidence
+		}
+		output.AppendMap(values)
+	}
+}
+
+func outputTable(statuses []*service.Status, rate *timecode.Rate) error {
+	output := util.NewOutput("type", "entity", "description", "start", "end", "confidence")
+	// Add value column if debug
+	if *FlagDebug {
+		output.AddColumns("value")
+	}
+	for _, status := range statuses {
+		outputResponse(status, rate, output)
+	}
+	output.RenderASCII()
+	return nil
+}
+
+// explicitConfig returns the thresholds for flagging explicit content ranges
+func explicitConfig() (*moderation.ExplicitConfig, error) {
+	config := &moderation.ExplicitConfig{
+		MinDuration: *FlagExplicitMin,
+		MergeGap:    *FlagExplicitGap,
+	}
+	var err error
+	if config.Enter, err = service.ParseLikelihood(*FlagExplicitEnter); err != nil {
+		return nil, fmt.Errorf("-explicit-enter: %v", err)
+	}
+	if config.Exit, err = service.ParseLikelihood(*FlagExplicitExit); err != nil {
+		return nil, fmt.Errorf("-explicit-exit: %v", err)
+	}
+	return config, nil
+}
+
+// explicitType returns the name of a type of explicit content
+func explicitType(dimension service.ExplicitDimension) string {
+	return strings.ToLower(strings.TrimPrefix(dimension.String(), "EXPLICIT_"))
+}
+
+func outputExplicitRanges(statuses []*service.Status, rate *timecode.Rate) error {
+	config, err := explicitConfig()
+	if err != nil {
+		return err
+	}
+	output := util.NewOutput("uri", "type", "start", "end", "duration", "peak", "frames")
+	for _, status := range statuses {
+		// Analyse each type of explicit content separately
+		for _, dimension := range moderation.Dimensions(status.Annotations) {
+			config.Dimension = dimension
+			summary, err := moderation.AnalyseExplicit(status.Annotations, config)
+			if err != nil {
+				return err
+			}
+			for _, r := range summary.Ranges {
+				output.AppendMap(map[string]interface{}{
+					"uri":      status.Uri,
+					"type":     explicitType(dimension),
+					"start":    formatOffset(rate, r.Start),
+					"end":      formatOffset(rate, r.End),
+					"duration": r.Duration(),
+					"peak":     r.Peak,
+					"frames":   r.Frames,
+				})
+			}
 			output.AppendMap(map[string]interface{}{
-				"type":        t,
-				"value":       label,
-				"entity":      label.Entity.EntityId,
-				"description": entity_description,
-				"start":       label.Segments[0].StartOffset,
-				"end":         label.Segments[0].EndOffset,
-				"confidence":  label.Segments[0].Confidence,
+				"uri":      status.Uri,
+				"type":     explicitType(dimension),
+				"start":    "total",
+				"end":      formatOffset(rate, summary.Runtime),
+				"duration": summary.Flagged,
+				"peak":     fmt.Sprintf("%.1f%% of runtime", summary.Percent),
+				"frames":   summary.Frames,
 			})
+		}
+	}
+	output.RenderASCII()
+	return nil
+}
+
+func outputEDL(statuses []*service.Status, rate *timecode.Rate) error {
+	edl := export.NewEDL("vi-analyse", rate)
+	for _, status := range statuses {
+		if err := edl.AddAnnotations(status.Uri, status.Annotations); err != nil {
+			return err
+		}
+	}
+	return edl.Write(os.Stdout)
+}
+
+func outputOTIO(statuses []*service.Status, rate *timecode.Rate) error {
+	otio := export.NewOTIO("vi-analyse", rate)
+	for _, status := range statuses {
+		if err := otio.AddAnnotations(status.Uri, status.Annotations); err != nil {
+			return err
+		}
+	}
+	return otio.Write(os.Stdout)
+}
+
+func outputHTML(statuses []*service.Status, rate *timecode.Rate) error {
+	html := export.NewHTML("vi-analyse", nil)
+	if *FlagTimecode {
+		html.Rate = rate
+	}
+	for _, status := range statuses {
+		if err := html.AddAnnotations(status.Uri, status.Annotations); err != nil {
+			return err
+		}
+	}
+	return html.Write(os.Stdout)
+}
+
+func outputJSON(statuses []*service.Status, rate *timecode.Rate) error {
+	json := export.NewJSON()
+	for _, status := range statuses {
+		if err := json.AddAnnotations(status.Uri, status.Annotations); err != nil {
+			return err
+		}
+	}
+	return json.Write(os.Stdout)
+}
+
+func outputTranscript(statuses []*service.Status, rate *timecode.Rate) error {
+	transcript := export.NewTranscript(*FlagFormat == "dialogue")
+	for _, status := range statuses {
+		if err := transcript.AddAnnotations(status.Uri, status.Annotations); err != nil {
+			return err
+		}
+	}
+	return transcript.Write(os.Stdout)
+}
+
+func outputCaptions(statuses []*service.Status, rate *timecode.Rate) error {
+	captions := export.NewCaptions(export.CAPTION_SRT)
+	if *FlagFormat == "vtt" {
+		captions.Format = export.CAPTION_VTT
+	}
+	for _, status := range statuses {
+		if err := captions.AddAnnotations(status.Uri, status.Annotations); err != nil {
+			return err
+		}
+	}
+	return captions.Write(os.Stdout)
+}
+
+func outputTimeline(statuses []*service.Status, rate *timecode.Rate) error {
+	for _, status := range statuses {
+		annotations := status.Annotations
+		chart := util.NewChart(status.Uri, annotations.Duration())
+		if *FlagTimecode {
+			chart.SetFormat(rate.FormatOffset)
+		}
+		if len(annotations.Shots) > 0 {
+			cuts := make([]time.Duration, 0, len(annotations.Shots))
+			for _, shot := range annotations.Shots {
+				if shot.StartOffset > 0 {
+					cuts = append(cuts, shot.StartOffset)
+				}
+			}
+			chart.AddTicks("shots", cuts)
+		}
+		for _, label := range timelineLabels(annotations, int(*FlagTop)) {
+			chart.AddSpans(label.description, label.starts, label.ends)
+		}
+		if len(annotations.ExplicitContent) > 0 {
+			offsets := make([]time.Duration, len(annotations.ExplicitContent))
+			levels := make([]int, len(annotations.ExplicitContent))
+			for i, annotation := range annotations.ExplicitContent {
+				offsets[i] = annotation.Offset
+				levels[i] = int(annotation.Likelihood)
+			}
+			chart.AddHeat("explicit_content", offsets, levels)
+		}
+		chart.RenderTerminal()
+	}
+	return nil
+}
+
+type timelineLabel struct {
+	description string
+	starts      []time.Duration
+	ends        []time.Duration
+	total       time.Duration
+}
+
+// timelineLabels returns shot and segment labels grouped by description,
+// with the longest total duration first
+func timelineLabels(annotations *service.Annotations, top int) []*timelineLabel {
+	labels := make(map[string]*timelineLabel)
+	sorted := make([]*timelineLabel, 0)
+	for _, group := range [][]*service.EntityAnnotation{annotations.ShotLabels, annotations.SegmentLabels} {
+		for _, annotation := range group {
+			label, exists := labels[annotation.Entity.Description]
+			if exists == false {
+				label = &timelineLabel{description: annotation.Entity.Description}
+				labels[label.description] = label
+				sorted = append(sorted, label)
+			}
+			for _, segment := range annotation.Segments {
+				label.starts = append(label.starts, segment.StartOffset)
+				label.ends = append(label.ends, segment.EndOffset)
+				label.total += segment.EndOffset - segment.StartOffset
+			}
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].total > sorted[j].total
+	})
+	if len(sorted) > top {
+		sorted = sorted[:top]
+	}
+	return sorted
+}
+
+// setCache sets the cache for annotations unless the -no-cache flag is set
+func setCache(api *service.Service) error {
+	if *FlagNoCache {
+		return nil
+	}
+	path, err := os.UserCacheDir()
+	if err != nil {
+		return err
+	}
+	cache, err := service.NewCache(filepath.Join(path, "vi-analyse"), *FlagCacheTTL)
+	if err != nil {
+		return err
+	}
+	api.SetCache(cache)
+	return nil
+}
+
+// openLedger returns the usage ledger in the user configuration folder
+func openLedger() (*service.Ledger, error) {
+	path, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return service.NewLedger(filepath.Join(path, "vi-analyse", "usage.jsonl"))
+}
+
+// setLedger sets the usage ledger and any budget limits
+func setLedger(api *service.Service) error {
+	ledger, err := openLedger()
+	if err != nil {
+		return err
+	}
+	config, err := estimateConfig()
+	if err != nil {
+		return err
+	}
+	api.SetLedger(ledger, config)
+	if *FlagBudgetDaily > 0 || *FlagBudgetMonthly > 0 || *FlagBudgetRun > 0 {
+		api.SetBudget(&service.BudgetPolicy{
+			Daily:   *FlagBudgetDaily,
+			Monthly: *FlagBudgetMonthly,
+			PerRun:  *FlagBudgetRun,
+		})
+	}
+	return nil
+}
+
+// probeInputs outputs the container metadata for local files
+func probeInputs(uris []string) {
+	for _, uri := range uris {
+		if service.IsCloudStorageUri(uri) {
+			continue
+		}
+		if info, err := probe.Open(uri); err == nil {
+			fmt.Fprintln(os.Stderr, uri, info)
+		}
+	}
+}
+
+// estimateConfig returns the detection parameters, manifest and price table
+// used for estimating costs
+func estimateConfig() (*service.EstimateConfig, error) {
+	config := new(service.EstimateConfig)
+	if detection, err := detectionConfig(); err != nil {
+		return nil, err
+	} else if detection != nil {
+		config.Config = *detection
+	}
+	if *FlagManifest != "" {
+		if file, err := os.Open(*FlagManifest); err != nil {
+			return nil, err
+		} else if config.Manifest, err = service.ReadManifest(file); err != nil {
+			file.Close()
+			return nil, err
 		} else {
-			output.AppendMap(map[string]interface{}{
-				"type":        "",
-				"value":       "",
-				"entity":      "",
-				"description": "",
-				"start":       label.Segments[i].StartOffset,
-				"end":         label.Segments[i].EndOffset,
-				"confidence":  label.Segments[i].Confidence,
-			})
+			file.Close()
+		}
+	}
+	if *FlagPricing != "" {
+		if file, err := os.Open(*FlagPricing); err != nil {
+			return nil, err
+		} else if config.Pricing, err = service.ReadPricing(file); err != nil {
+			file.Close()
+			return nil, err
+		} else {
+			file.Close()
 		}
 	}
+	return config, nil
 }
 
-func outputResponse(status *service.Status, output *util.Output) {
-	if len(status.Annotations.Shots) > 0 {
-		for _, shot := range status.Annotations.Shots {
+func runEstimate(uris []string) error {
+	if len(uris) == 0 {
+		return errors.New("Missing uri arguments")
+	}
+	flags, err := annotationFlags()
+	if err != nil {
+		return err
+	}
+	config, err := estimateConfig()
+	if err != nil {
+		return err
+	}
+
+	// Output the estimate for each video and feature, and then the totals
+	estimate, err := service.Estimate(uris, flags, config)
+	if estimate == nil {
+		return err
+	}
+	output := util.NewOutput("uri", "duration", "feature", "minutes", "free", "cost")
+	for _, video := range estimate.Videos {
+		for _, annotationType := range sortedTypes(video.Minutes) {
 			output.AppendMap(map[string]interface{}{
-				"type":  "shot",
-				"start": shot.StartOffset,
-				"end":   shot.EndOffset,
-				"value": shot,
+				"uri":      video.Uri,
+				"duration": video.Duration,
+				"feature":  annotationType,
+				"minutes":  video.Minutes[annotationType],
+				"free":     video.FreeMinutes[annotationType],
+				"cost":     fmt.Sprintf("%.2f %v", video.Cost[annotationType], estimate.Currency),
 			})
 		}
 	}
-	if len(status.Annotations.ShotLabels) > 0 {
-		for _, label := range status.Annotations.ShotLabels {
-			outputResponseEntity(output, "shot_label", label)
-		}
+	for _, annotationType := range sortedTypes(estimate.Minutes) {
+		output.AppendMap(map[string]interface{}{
+			"uri":     "total",
+			"feature": annotationType,
+			"minutes": estimate.Minutes[annotationType],
+			"free":    estimate.FreeMinutes[annotationType],
+			"cost":    fmt.Sprintf("%.2f %v", estimate.Cost[annotationType], estimate.Currency),
+		})
+	}
+	output.AppendMap(map[string]interface{}{
+		"uri":  "total",
+		"cost": fmt.Sprintf("%.2f %v", estimate.Total, estimate.Currency),
+	})
+	output.RenderASCII()
+
+	// Return any errors for inputs with unknown durations
+	return err
+}
+
+// runUsage outputs the recorded usage by day and feature, and then the totals
+func runUsage() error {
+	ledger, err := openLedger()
+	if err != nil {
+		return err
+	}
+	entries, err := ledger.Entries(time.Time{})
+	if err != nil {
+		return err
 	}
-	if len(status.Annotations.SegmentLabels) > 0 {
-		for _, label := range status.Annotations.SegmentLabels {
-			outputResponseEntity(output, "segment_label", label)
+	type usage struct {
+		videos  int
+		minutes int64
+		cost    float64
+	}
+	days := make([]string, 0)
+	features := make(map[string]map[string]*usage)
+	totals := make(map[string]*usage)
+	currency := ""
+	for _, entry := range entries {
+		day := entry.Time.Local().Format("2006-01-02")
+		if _, exists := features[day]; exists == false {
+			days = append(days, day)
+			features[day] = make(map[string]*usage)
+		}
+		for feature, minutes := range entry.Minutes {
+			for _, values := range []map[string]*usage{features[day], totals} {
+				if _, exists := values[feature]; exists == false {
+					values[feature] = new(usage)
+				}
+				values[feature].videos += 1
+				values[feature].minutes += minutes
+				values[feature].cost += entry.Cost[feature]
+			}
 		}
+		currency = entry.Currency
 	}
-	if len(status.Annotations.ExplicitContent) > 0 {
-		for _, annotation := range status.Annotations.ExplicitContent {
+	sort.Strings(days)
+
+	output := util.NewOutput("day", "feature", "videos", "minutes", "cost")
+	appendUsage := func(day string, values map[string]*usage) {
+		names := make([]string, 0, len(values))
+		for feature := range values {
+			names = append(names, feature)
+		}
+		sort.Strings(names)
+		for _, feature := range names {
 			output.AppendMap(map[string]interface{}{
-				"type":       "explicit_content",
-				"start":      annotation.Offset,
-				"confidence": annotation.Likelihood,
-				"value":      annotation,
+				"day":     day,
+				"feature": feature,
+				"videos":  values[feature].videos,
+				"minutes": values[feature].minutes,
+				"cost":    fmt.Sprintf("%.2f %v", values[feature].cost, currency),
 			})
 		}
 	}
-
+	for _, day := range days {
+		appendUsage(day, features[day])
+	}
+	appendUsage("total", totals)
+	var total float64
+	for _, entry := range entries {
+		total += entry.Total
+	}
+	output.AppendMap(map[string]interface{}{
+		"day":    "total",
+		"videos": len(entries),
+		"cost":   fmt.Sprintf("%.2f %v", total, currency),
+	})
 	output.RenderASCII()
+	return nil
+}
+
+// sortedTypes returns the annotation types in a map in order
+func sortedTypes(values map[service.AnnotationType]int64) []service.AnnotationType {
+	types := make([]service.AnnotationType, 0, len(values))
+	for annotationType := range values {
+		types = append(types, annotationType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+	return types
+}
+
+// evaluatePolicy outputs the verdict for each video to stderr, and returns
+// the most severe verdict. Videos which failed to be annotated are blocked
+func evaluatePolicy(rules *policy.Policy, statuses []*service.Status, failed map[string]error, rate *timecode.Rate) (policy.Verdict, error) {
+	verdict := policy.VERDICT_ALLOW
+	output := util.NewOutput("uri", "verdict", "rule", "description", "start", "end", "confidence")
+	for _, status := range statuses {
+		if err, exists := failed[status.Name]; exists {
+			verdict = policy.VERDICT_BLOCK
+			output.AppendMap(map[string]interface{}{
+				"uri":         status.Uri,
+				"verdict":     policy.VERDICT_BLOCK,
+				"description": err,
+			})
+			continue
+		}
+		result, err := rules.Evaluate(status.Annotations)
+		if err != nil {
+			return verdict, err
+		}
+		if result.Verdict > verdict {
+			verdict = result.Verdict
+		}
+		output.AppendMap(map[string]interface{}{
+			"uri":     status.Uri,
+			"verdict": result.Verdict,
+		})
+		for _, evidence := range result.Evidence {
+			row := map[string]interface{}{
+				"verdict":     evidence.Verdict,
+				"rule":        evidence.Rule,
+				"description": evidence.Description,
+				"start":       formatOffset(rate, evidence.Start),
+				"end":         formatOffset(rate, evidence.End),
+				"confidence":  evidence.Confidence,
+			}
+			if evidence.Likelihood != service.LIKELIHOOD_UNSPECIFIED {
+				row["confidence"] = evidence.Likelihood
+			}
+			output.AppendMap(row)
+		}
+	}
+	output.RenderASCIIWriter(os.Stderr)
+	return verdict, nil
 }
 
-func runMain(api *service.Service, uris []string) error {
+func runMain(annotator service.Annotator, uris []string) (policy.Verdict, error) {
 	if len(uris) == 0 {
-		return errors.New("Missing uri arguments")
+		return policy.VERDICT_ALLOW, errors.New("Missing uri arguments")
+	}
+	flags, err := annotationFlags()
+	if err != nil {
+		return policy.VERDICT_ALLOW, err
+	}
+	config, err := detectionConfig()
+	if err != nil {
+		return policy.VERDICT_ALLOW, err
+	}
+	// Request the annotation types which the policy needs
+	var rules *policy.Policy
+	if *FlagPolicy != "" {
+		if rules, err = policy.Load(*FlagPolicy); err != nil {
+			return policy.VERDICT_ALLOW, err
+		}
+		flags |= rules.AnnotationTypes()
 	}
 
-	// Gather output
-	output := util.NewOutput("type", "entity", "description", "start", "end", "confidence")
-	// Add value column if debug
-	if *FlagDebug {
-		output.AddColumns("value")
+	// Determine the frame rate and output format
+	rate, err := timecode.NewRate(*FlagFrameRate, *FlagDropFrame)
+	if err != nil {
+		return policy.VERDICT_ALLOW, err
+	}
+	var output func([]*service.Status, *timecode.Rate) error
+	switch *FlagFormat {
+	case "table":
+		output = outputTable
+	case "edl":
+		output = outputEDL
+	case "otio":
+		output = outputOTIO
+	case "html":
+		output = outputHTML
+	case "json":
+		output = outputJSON
+	case "transcript", "dialogue":
+		output = outputTranscript
+	case "srt", "vtt":
+		if len(uris) > 1 {
+			return policy.VERDICT_ALLOW, export.ErrTooManyVideos
+		}
+		output = outputCaptions
+	case "timeline":
+		output = outputTimeline
+	default:
+		return policy.VERDICT_ALLOW, fmt.Errorf("Invalid output format: %v", *FlagFormat)
+	}
+	if *FlagExplicitRanges {
+		if _, err := explicitConfig(); err != nil {
+			return policy.VERDICT_ALLOW, err
+		}
+		output = outputExplicitRanges
+	}
+
+	// Validate the inputs before submitting any of them, and set up the
+	// cache and usage ledger, which are only supported for Google
+	api, _ := annotator.(*service.Service)
+	if api != nil {
+		if err := service.ValidateInputs(uris); err != nil {
+			return policy.VERDICT_ALLOW, err
+		}
+		if *FlagDebug {
+			probeInputs(uris)
+		}
+		if err := setCache(api); err != nil {
+			return policy.VERDICT_ALLOW, err
+		}
+		if err := setLedger(api); err != nil {
+			return policy.VERDICT_ALLOW, err
+		}
 	}
 
-	// Return Annotate result for each URI
+	// Start annotating each URI, so that the operations run in parallel
+	operations := make([]string, 0, len(uris))
+	started := make(map[string]time.Time, len(uris))
 	for _, uri := range uris {
-		if operation, err := api.Annotate(uri, annotationFlags()); err != nil {
-			return err
+		if *FlagRefresh && api != nil {
+			if err := api.InvalidateCache(uri, flags, config); err != nil && err != service.ErrNotCacheable {
+				return policy.VERDICT_ALLOW, err
+			}
+		}
+		if operation, err := annotator.AnnotateWithConfig(uri, flags, config); err != nil {
+			return policy.VERDICT_ALLOW, err
 		} else {
-			for {
-				status, err := api.Status(operation)
-				if err != nil {
-					return err
-				}
-				fmt.Printf("Percent Complete=%v%%\n", status.PercentComplete())
-				if status.Done {
-					outputResponse(status, output)
-					break
-				} else {
-					time.Sleep(1 * time.Second)
-				}
+			operations = append(operations, operation)
+			started[operation] = time.Now()
+		}
+	}
+
+	// Poll the operations until they have all completed, displaying the
+	// progress of each operation and feature. When there is a policy, failed
+	// operations are blocked rather than stopping the other operations
+	statuses := make([]*service.Status, len(operations))
+	failed := make(map[string]error)
+	display := util.NewProgress(progress_LOG_INTERVAL)
+	for {
+		done := true
+		for i, operation := range operations {
+			if statuses[i] != nil && statuses[i].Done {
+				continue
+			} else if status, err := annotator.Status(operation); err == nil {
+				statuses[i] = status
+				done = done && status.Done
+			} else if _, isOperationError := err.(*service.OperationError); isOperationError && rules != nil {
+				statuses[i] = &service.Status{Name: operation, Uri: uris[i], Done: true, Updated: time.Now()}
+				failed[operation] = err
+			} else {
+				return policy.VERDICT_ALLOW, err
 			}
 		}
+		rows := progressRows(statuses, started, failed)
+		if done {
+			display.Done(rows)
+			break
+		}
+		display.Update(rows)
+		time.Sleep(1 * time.Second)
 	}
 
-	// success
-	return nil
+	// Output the annotations of the completed operations
+	completed := make([]*service.Status, 0, len(statuses))
+	for _, status := range statuses {
+		if _, exists := failed[status.Name]; exists == false {
+			completed = append(completed, status)
+		}
+	}
+	if *FlagTimecode {
+		for _, status := range completed {
+			status.Annotations = rate.SnapAnnotations(status.Annotations)
+		}
+	}
+	if err := output(completed, rate); err != nil {
+		return policy.VERDICT_ALLOW, err
+	}
+
+	// Evaluate the policy
+	if rules == nil {
+		return policy.VERDICT_ALLOW, nil
+	}
+	return evaluatePolicy(rules, statuses, failed, rate)
 }
 
 func main() {
 	// Parse command-line flags
 	flag.Parse()
 
-	// Obtain the filename (if relative path, then make it absolute relative to home folder)
-	if serviceAccountPath, err := filenameToAbsolute(*FlagServiceAccount); err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
-		os.Exit(-1)
-	} else if service, err := service.NewServiceFromServiceAccountJSON(serviceAccountPath, *FlagDebug); err != nil {
+	// Usage reports and estimates don't need to submit anything
+	if flag.NArg() > 0 && flag.Arg(0) == "usage" {
+		if err := runUsage(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(-1)
+		}
+		return
+	}
+	if *FlagEstimate {
+		if err := runEstimate(flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(-1)
+		}
+		return
+	}
+
+	// Create the annotator for the provider
+	if annotator, err := newAnnotator(); err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(-1)
-	} else if err := runMain(service, flag.Args()); err != nil {
+	} else if verdict, err := runMain(annotator, flag.Args()); err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(-1)
+	} else {
+		os.Exit(verdictExitCode[verdict])
+	}
+}
+
+// apiVersion returns the API version from the -api flag. When the flag isn't
+// set and a requested feature isn't supported by the default version, v1
+// is used instead
+func apiVersion() (service.Version, error) {
+	version, err := service.ParseVersion(*FlagVersion)
+	if err != nil {
+		return version, err
+	}
+	flags, err := annotationFlags()
+	if err != nil {
+		return version, err
+	}
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "api" {
+			explicit = true
+		}
+	})
+	if err := service.CheckFeatures(version, flags); err == nil {
+		return version, nil
+	} else if explicit {
+		return version, fmt.Errorf("%v (use -api v1)", err)
+	} else {
+		return service.VERSION_V1, nil
+	}
+}
+
+func newAnnotator() (service.Annotator, error) {
+	switch *FlagProvider {
+	case "google":
+		break
+	case "rekognition":
+		// Credentials and region are read from the AWS environment variables
+		return rekognition.NewFromEnvironment()
+	default:
+		return nil, fmt.Errorf("Invalid provider: %v", *FlagProvider)
+	}
+
+	// Obtain the filename (if relative path, then make it absolute relative to home folder)
+	if serviceAccountPath, err := filenameToAbsolute(*FlagServiceAccount); err != nil {
+		return nil, err
+	} else if version, err := apiVersion(); err != nil {
+		return nil, err
+	} else {
+		return service.NewServiceFromServiceAccountJSON(serviceAccountPath, *FlagDebug, service.WithVersion(version))
 	}
 }